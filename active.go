@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrStatementKilled is wrapped by errors from statements interrupted by
+// KillStatement.
+var ErrStatementKilled = errors.New("statement killed")
+
+// ActiveStmt describes a statement that has started running on a connection
+// and not yet finished, including queries whose rows are still open.
+type ActiveStmt struct {
+	ID      int
+	SQL     string
+	Started time.Time
+}
+
+// ActiveStatements returns the connection's running statements in the order
+// they started. Unlike most Conn methods it does not wait for a running
+// statement, so it can be called from another goroutine while one runs.
+func (c *Conn) ActiveStatements() []ActiveStmt {
+	var active []ActiveStmt
+	for _, stmt := range c.active.Iter() {
+		active = append(active, stmt)
+	}
+	slices.SortFunc(active, func(a, b ActiveStmt) int {
+		return a.ID - b.ID
+	})
+	return active
+}
+
+// KillStatement interrupts the active statement with the given ID, which
+// then fails with an error wrapping ErrStatementKilled. SQLite interrupts
+// every running statement of the connection at once, so any other statement
+// active at the same time, such as a query with open rows in the same
+// transaction, fails too. Like ActiveStatements it can be called from
+// another goroutine.
+func (c *Conn) KillStatement(id int) error {
+	if _, ok := c.active.Load(id); !ok {
+		return fmt.Errorf("no active statement %d", id)
+	}
+	// The running statement holds c.mu, so closeMu keeps Close from
+	// freeing the handle while it is interrupted.
+	c.closeMu.RLock()
+	defer c.closeMu.RUnlock()
+
+	if c.closed.Load() {
+		return nil
+	}
+
+	c.killed.Store(true)
+	sqlite3_interrupt(c.db)
+	return nil
+}
+
+// track records the statement as active. The caller must hold c.mu.
+func (s *Stmt) track() {
+	s.untrack()
+	s.activeID = int(s.conn.nextActiveID.Add(1))
+	s.conn.active.Store(s.activeID, ActiveStmt{
+		ID:      s.activeID,
+		SQL:     s.query,
+		Started: time.Now(),
+	})
+}
+
+// untrack removes the statement from the active ones and stops its clock once
+// it has finished, failed or been reset. The caller must hold c.mu.
+func (s *Stmt) untrack() {
+	if s.activeID != 0 {
+		s.conn.active.Delete(s.activeID)
+		s.activeID = 0
+		s.conn.endQuery()
+	}
+}
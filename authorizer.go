@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// RegisterAuthorizer registers fn to be consulted while statements are
+// compiled. action is one of the SQLITE_* action codes such as SQLITE_INSERT
+// or SQLITE_READ; the meaning of arg1 and arg2 depends on the action. fn
+// returns SQLITE_OK to allow the operation, SQLITE_DENY to fail the statement
+// with an authorization error, or SQLITE_IGNORE to treat it as a no-op (or
+// read NULL for SQLITE_READ). Passing nil removes the authorizer.
+//
+// Statements prepared before the call are recompiled against the new
+// authorizer when next run. fn must not call back into the Conn.
+func (c *Conn) RegisterAuthorizer(fn func(action int, arg1, arg2, dbName, trigger string) int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	c.authorizer = fn
+
+	var rc int
+	if fn == nil {
+		rc = sqlite3_set_authorizer(c.db, 0, 0)
+	} else {
+		initCallbacks()
+		rc = sqlite3_set_authorizer(c.db, authCallback, c.handle)
+	}
+
+	if rc != SQLITE_OK {
+		return fmt.Errorf("set authorizer failed: %w", c.lastError())
+	}
+
+	return nil
+}
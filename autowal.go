@@ -0,0 +1,35 @@
+package sqlite
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// networkFilesystem reports whether path is on a network filesystem. It is a
+// variable so tests can simulate one.
+var networkFilesystem = isNetworkFilesystem
+
+// autoJournalMode switches the database to WAL, or to DELETE when it lives on
+// a network filesystem whose locking cannot support WAL's shared memory.
+func (c *Conn) autoJournalMode() error {
+	path := c.cfg.path
+	if path == "" || path == ":memory:" {
+		return nil
+	}
+
+	// The database file may not exist yet, so inspect its directory. A
+	// filesystem that cannot be inspected is assumed to be local.
+	network, _ := networkFilesystem(filepath.Dir(path))
+
+	mode := "WAL"
+	if network {
+		log.Printf("sqlite: %s is on a network filesystem, using journal_mode=DELETE instead of WAL", path)
+		mode = "DELETE"
+	}
+
+	if _, err := c.execDirect("PRAGMA journal_mode=" + mode); err != nil {
+		return fmt.Errorf("set journal mode %s: %w", mode, err)
+	}
+	return nil
+}
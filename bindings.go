@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/ebitengine/purego"
 )
@@ -44,6 +48,79 @@ const (
 	SQLITE_ROW        = 100
 	SQLITE_DONE       = 101
 
+	SQLITE_ERROR_MISSING_COLLSEQ   = SQLITE_ERROR | 1<<8
+	SQLITE_ERROR_RETRY             = SQLITE_ERROR | 2<<8
+	SQLITE_ERROR_SNAPSHOT          = SQLITE_ERROR | 3<<8
+	SQLITE_IOERR_READ              = SQLITE_IOERR | 1<<8
+	SQLITE_IOERR_SHORT_READ        = SQLITE_IOERR | 2<<8
+	SQLITE_IOERR_WRITE             = SQLITE_IOERR | 3<<8
+	SQLITE_IOERR_FSYNC             = SQLITE_IOERR | 4<<8
+	SQLITE_IOERR_DIR_FSYNC         = SQLITE_IOERR | 5<<8
+	SQLITE_IOERR_TRUNCATE          = SQLITE_IOERR | 6<<8
+	SQLITE_IOERR_FSTAT             = SQLITE_IOERR | 7<<8
+	SQLITE_IOERR_UNLOCK            = SQLITE_IOERR | 8<<8
+	SQLITE_IOERR_RDLOCK            = SQLITE_IOERR | 9<<8
+	SQLITE_IOERR_DELETE            = SQLITE_IOERR | 10<<8
+	SQLITE_IOERR_BLOCKED           = SQLITE_IOERR | 11<<8
+	SQLITE_IOERR_NOMEM             = SQLITE_IOERR | 12<<8
+	SQLITE_IOERR_ACCESS            = SQLITE_IOERR | 13<<8
+	SQLITE_IOERR_CHECKRESERVEDLOCK = SQLITE_IOERR | 14<<8
+	SQLITE_IOERR_LOCK              = SQLITE_IOERR | 15<<8
+	SQLITE_IOERR_CLOSE             = SQLITE_IOERR | 16<<8
+	SQLITE_IOERR_DIR_CLOSE         = SQLITE_IOERR | 17<<8
+	SQLITE_IOERR_SHMOPEN           = SQLITE_IOERR | 18<<8
+	SQLITE_IOERR_SHMSIZE           = SQLITE_IOERR | 19<<8
+	SQLITE_IOERR_SHMLOCK           = SQLITE_IOERR | 20<<8
+	SQLITE_IOERR_SHMMAP            = SQLITE_IOERR | 21<<8
+	SQLITE_IOERR_SEEK              = SQLITE_IOERR | 22<<8
+	SQLITE_IOERR_DELETE_NOENT      = SQLITE_IOERR | 23<<8
+	SQLITE_IOERR_MMAP              = SQLITE_IOERR | 24<<8
+	SQLITE_IOERR_GETTEMPPATH       = SQLITE_IOERR | 25<<8
+	SQLITE_IOERR_CONVPATH          = SQLITE_IOERR | 26<<8
+	SQLITE_IOERR_VNODE             = SQLITE_IOERR | 27<<8
+	SQLITE_IOERR_AUTH              = SQLITE_IOERR | 28<<8
+	SQLITE_IOERR_BEGIN_ATOMIC      = SQLITE_IOERR | 29<<8
+	SQLITE_IOERR_COMMIT_ATOMIC     = SQLITE_IOERR | 30<<8
+	SQLITE_IOERR_ROLLBACK_ATOMIC   = SQLITE_IOERR | 31<<8
+	SQLITE_IOERR_DATA              = SQLITE_IOERR | 32<<8
+	SQLITE_IOERR_CORRUPTFS         = SQLITE_IOERR | 33<<8
+	SQLITE_LOCKED_SHAREDCACHE      = SQLITE_LOCKED | 1<<8
+	SQLITE_LOCKED_VTAB             = SQLITE_LOCKED | 2<<8
+	SQLITE_BUSY_RECOVERY           = SQLITE_BUSY | 1<<8
+	SQLITE_BUSY_SNAPSHOT           = SQLITE_BUSY | 2<<8
+	SQLITE_BUSY_TIMEOUT            = SQLITE_BUSY | 3<<8
+	SQLITE_CANTOPEN_NOTEMPDIR      = SQLITE_CANTOPEN | 1<<8
+	SQLITE_CANTOPEN_ISDIR          = SQLITE_CANTOPEN | 2<<8
+	SQLITE_CANTOPEN_FULLPATH       = SQLITE_CANTOPEN | 3<<8
+	SQLITE_CANTOPEN_CONVPATH       = SQLITE_CANTOPEN | 4<<8
+	SQLITE_CANTOPEN_SYMLINK        = SQLITE_CANTOPEN | 6<<8
+	SQLITE_CORRUPT_VTAB            = SQLITE_CORRUPT | 1<<8
+	SQLITE_CORRUPT_SEQUENCE        = SQLITE_CORRUPT | 2<<8
+	SQLITE_CORRUPT_INDEX           = SQLITE_CORRUPT | 3<<8
+	SQLITE_READONLY_RECOVERY       = SQLITE_READONLY | 1<<8
+	SQLITE_READONLY_CANTLOCK       = SQLITE_READONLY | 2<<8
+	SQLITE_READONLY_ROLLBACK       = SQLITE_READONLY | 3<<8
+	SQLITE_READONLY_DBMOVED        = SQLITE_READONLY | 4<<8
+	SQLITE_READONLY_CANTINIT       = SQLITE_READONLY | 5<<8
+	SQLITE_READONLY_DIRECTORY      = SQLITE_READONLY | 6<<8
+	SQLITE_ABORT_ROLLBACK          = SQLITE_ABORT | 2<<8
+	SQLITE_CONSTRAINT_CHECK        = SQLITE_CONSTRAINT | 1<<8
+	SQLITE_CONSTRAINT_COMMITHOOK   = SQLITE_CONSTRAINT | 2<<8
+	SQLITE_CONSTRAINT_FOREIGNKEY   = SQLITE_CONSTRAINT | 3<<8
+	SQLITE_CONSTRAINT_FUNCTION     = SQLITE_CONSTRAINT | 4<<8
+	SQLITE_CONSTRAINT_NOTNULL      = SQLITE_CONSTRAINT | 5<<8
+	SQLITE_CONSTRAINT_PRIMARYKEY   = SQLITE_CONSTRAINT | 6<<8
+	SQLITE_CONSTRAINT_TRIGGER      = SQLITE_CONSTRAINT | 7<<8
+	SQLITE_CONSTRAINT_UNIQUE       = SQLITE_CONSTRAINT | 8<<8
+	SQLITE_CONSTRAINT_VTAB         = SQLITE_CONSTRAINT | 9<<8
+	SQLITE_CONSTRAINT_ROWID        = SQLITE_CONSTRAINT | 10<<8
+	SQLITE_CONSTRAINT_PINNED       = SQLITE_CONSTRAINT | 11<<8
+	SQLITE_CONSTRAINT_DATATYPE     = SQLITE_CONSTRAINT | 12<<8
+	SQLITE_NOTICE_RECOVER_WAL      = SQLITE_NOTICE | 1<<8
+	SQLITE_NOTICE_RECOVER_ROLLBACK = SQLITE_NOTICE | 2<<8
+	SQLITE_WARNING_AUTOINDEX       = SQLITE_WARNING | 1<<8
+	SQLITE_AUTH_USER               = SQLITE_AUTH | 1<<8
+
 	SQLITE_OPEN_READONLY  = 0x00000001
 	SQLITE_OPEN_READWRITE = 0x00000002
 	SQLITE_OPEN_CREATE    = 0x00000004
@@ -57,6 +134,127 @@ const (
 	SQLITE_OPEN_SHAREDCACHE  = 0x00020000
 	SQLITE_OPEN_PRIVATECACHE = 0x00040000
 
+	SQLITE_DBCONFIG_ENABLE_FKEY           = 1002
+	SQLITE_DBCONFIG_ENABLE_TRIGGER        = 1003
+	SQLITE_DBCONFIG_ENABLE_FTS3_TOKENIZER = 1004
+	SQLITE_DBCONFIG_ENABLE_LOAD_EXTENSION = 1005
+	SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE      = 1006
+	SQLITE_DBCONFIG_ENABLE_QPSG           = 1007
+	SQLITE_DBCONFIG_TRIGGER_EQP           = 1008
+	SQLITE_DBCONFIG_RESET_DATABASE        = 1009
+	SQLITE_DBCONFIG_DEFENSIVE             = 1010
+	SQLITE_DBCONFIG_WRITABLE_SCHEMA       = 1011
+	SQLITE_DBCONFIG_LEGACY_ALTER_TABLE    = 1012
+	SQLITE_DBCONFIG_DQS_DML               = 1013
+	SQLITE_DBCONFIG_DQS_DDL               = 1014
+	SQLITE_DBCONFIG_ENABLE_VIEW           = 1015
+	SQLITE_DBCONFIG_LEGACY_FILE_FORMAT    = 1016
+	SQLITE_DBCONFIG_TRUSTED_SCHEMA        = 1017
+
+	SQLITE_TRACE_STMT    = 0x01
+	SQLITE_TRACE_PROFILE = 0x02
+	SQLITE_TRACE_ROW     = 0x04
+	SQLITE_TRACE_CLOSE   = 0x08
+
+	SQLITE_DENY   = 1
+	SQLITE_IGNORE = 2
+
+	SQLITE_CREATE_INDEX        = 1
+	SQLITE_CREATE_TABLE        = 2
+	SQLITE_CREATE_TEMP_INDEX   = 3
+	SQLITE_CREATE_TEMP_TABLE   = 4
+	SQLITE_CREATE_TEMP_TRIGGER = 5
+	SQLITE_CREATE_TEMP_VIEW    = 6
+	SQLITE_CREATE_TRIGGER      = 7
+	SQLITE_CREATE_VIEW         = 8
+	SQLITE_DELETE              = 9
+	SQLITE_DROP_INDEX          = 10
+	SQLITE_DROP_TABLE          = 11
+	SQLITE_DROP_TEMP_INDEX     = 12
+	SQLITE_DROP_TEMP_TABLE     = 13
+	SQLITE_DROP_TEMP_TRIGGER   = 14
+	SQLITE_DROP_TEMP_VIEW      = 15
+	SQLITE_DROP_TRIGGER        = 16
+	SQLITE_DROP_VIEW           = 17
+	SQLITE_INSERT              = 18
+	SQLITE_PRAGMA              = 19
+	SQLITE_READ                = 20
+	SQLITE_SELECT              = 21
+	SQLITE_TRANSACTION         = 22
+	SQLITE_UPDATE              = 23
+	SQLITE_ATTACH              = 24
+	SQLITE_DETACH              = 25
+	SQLITE_ALTER_TABLE         = 26
+	SQLITE_REINDEX             = 27
+	SQLITE_ANALYZE             = 28
+	SQLITE_CREATE_VTABLE       = 29
+	SQLITE_DROP_VTABLE         = 30
+	SQLITE_FUNCTION            = 31
+	SQLITE_SAVEPOINT           = 32
+	SQLITE_RECURSIVE           = 33
+
+	SQLITE_TXN_NONE  = 0
+	SQLITE_TXN_READ  = 1
+	SQLITE_TXN_WRITE = 2
+
+	SQLITE_CHECKPOINT_PASSIVE  = 0
+	SQLITE_CHECKPOINT_FULL     = 1
+	SQLITE_CHECKPOINT_RESTART  = 2
+	SQLITE_CHECKPOINT_TRUNCATE = 3
+
+	SQLITE_SCANSTAT_NLOOP    = 0
+	SQLITE_SCANSTAT_NVISIT   = 1
+	SQLITE_SCANSTAT_EST      = 2
+	SQLITE_SCANSTAT_NAME     = 3
+	SQLITE_SCANSTAT_EXPLAIN  = 4
+	SQLITE_SCANSTAT_SELECTID = 5
+
+	SQLITE_UTF8 = 1
+
+	SQLITE_DETERMINISTIC  = 0x000000800
+	SQLITE_SUBTYPE        = 0x000100000
+	SQLITE_RESULT_SUBTYPE = 0x001000000
+
+	FTS5_TOKENIZE_QUERY    = 0x0001
+	FTS5_TOKENIZE_PREFIX   = 0x0002
+	FTS5_TOKENIZE_DOCUMENT = 0x0004
+	FTS5_TOKENIZE_AUX      = 0x0008
+
+	SQLITE_DBSTATUS_LOOKASIDE_USED      = 0
+	SQLITE_DBSTATUS_CACHE_USED          = 1
+	SQLITE_DBSTATUS_SCHEMA_USED         = 2
+	SQLITE_DBSTATUS_STMT_USED           = 3
+	SQLITE_DBSTATUS_LOOKASIDE_HIT       = 4
+	SQLITE_DBSTATUS_LOOKASIDE_MISS_SIZE = 5
+	SQLITE_DBSTATUS_LOOKASIDE_MISS_FULL = 6
+	SQLITE_DBSTATUS_CACHE_HIT           = 7
+	SQLITE_DBSTATUS_CACHE_MISS          = 8
+	SQLITE_DBSTATUS_CACHE_WRITE         = 9
+	SQLITE_DBSTATUS_DEFERRED_FKS        = 10
+	SQLITE_DBSTATUS_CACHE_USED_SHARED   = 11
+	SQLITE_DBSTATUS_CACHE_SPILL         = 12
+
+	SQLITE_STATUS_MEMORY_USED        = 0
+	SQLITE_STATUS_PAGECACHE_USED     = 1
+	SQLITE_STATUS_PAGECACHE_OVERFLOW = 2
+	SQLITE_STATUS_MALLOC_SIZE        = 5
+	SQLITE_STATUS_PARSER_STACK       = 6
+	SQLITE_STATUS_PAGECACHE_SIZE     = 7
+	SQLITE_STATUS_MALLOC_COUNT       = 9
+
+	SQLITE_LIMIT_LENGTH              = 0
+	SQLITE_LIMIT_SQL_LENGTH          = 1
+	SQLITE_LIMIT_COLUMN              = 2
+	SQLITE_LIMIT_EXPR_DEPTH          = 3
+	SQLITE_LIMIT_COMPOUND_SELECT     = 4
+	SQLITE_LIMIT_VDBE_OP             = 5
+	SQLITE_LIMIT_FUNCTION_ARG        = 6
+	SQLITE_LIMIT_ATTACHED            = 7
+	SQLITE_LIMIT_LIKE_PATTERN_LENGTH = 8
+	SQLITE_LIMIT_VARIABLE_NUMBER     = 9
+	SQLITE_LIMIT_TRIGGER_DEPTH       = 10
+	SQLITE_LIMIT_WORKER_THREADS      = 11
+
 	SQLITE_INTEGER = 1
 	SQLITE_REAL    = 2
 	SQLITE_TEXT    = 3
@@ -65,16 +263,21 @@ const (
 )
 
 var (
-	libsqlite3 uintptr
-	initOnce   sync.Once
-	initErr    error
+	libsqlite3  uintptr
+	libraryPath string // Path the library was loaded from
+	loadMu      sync.Mutex
+	loaded      atomic.Bool
+
+	libraryPathsMu sync.Mutex
+	libraryPaths   []string
 
 	sqlite3_open_v2              func(filename uintptr, ppDb *uintptr, flags int, zVfs uintptr) int
 	sqlite3_close                func(db uintptr) int
-	sqlite3_prepare_v2           func(db uintptr, zSql uintptr, nByte int, ppStmt *uintptr, pzTail uintptr) int
+	sqlite3_prepare_v2           func(db uintptr, zSql uintptr, nByte int, ppStmt *uintptr, pzTail *uintptr) int
 	sqlite3_step                 func(stmt uintptr) int
 	sqlite3_finalize             func(stmt uintptr) int
 	sqlite3_reset                func(stmt uintptr) int
+	sqlite3_clear_bindings       func(stmt uintptr) int
 	sqlite3_column_count         func(stmt uintptr) int
 	sqlite3_column_name          func(stmt uintptr, n int) uintptr
 	sqlite3_column_decltype      func(stmt uintptr, n int) uintptr
@@ -85,70 +288,201 @@ var (
 	sqlite3_column_blob          func(stmt uintptr, iCol int) uintptr
 	sqlite3_column_bytes         func(stmt uintptr, iCol int) int
 	sqlite3_bind_parameter_count func(stmt uintptr) int
+	sqlite3_bind_parameter_index func(stmt uintptr, zName uintptr) int
+	sqlite3_bind_parameter_name  func(stmt uintptr, idx int) uintptr
+	sqlite3_stmt_readonly        func(stmt uintptr) int
 	sqlite3_bind_null            func(stmt uintptr, idx int) int
 	sqlite3_bind_int64           func(stmt uintptr, idx int, val int64) int
 	sqlite3_bind_double          func(stmt uintptr, idx int, val float64) int
 	sqlite3_bind_text            func(stmt uintptr, idx int, val uintptr, n int, destructor uintptr) int
 	sqlite3_bind_blob            func(stmt uintptr, idx int, val uintptr, n int, destructor uintptr) int
+	sqlite3_bind_zeroblob        func(stmt uintptr, idx int, n int) int
 	sqlite3_last_insert_rowid    func(db uintptr) int64
 	sqlite3_changes              func(db uintptr) int
+	sqlite3_total_changes        func(db uintptr) int
+	sqlite3_get_autocommit       func(db uintptr) int
 	sqlite3_errmsg               func(db uintptr) uintptr
 	sqlite3_errcode              func(db uintptr) int
 	sqlite3_exec                 func(db uintptr, sql uintptr, callback uintptr, arg uintptr, errmsg uintptr) int
 	sqlite3_interrupt            func(db uintptr)
 	sqlite3_busy_handler         func(db uintptr, callback uintptr, arg uintptr) int
 	sqlite3_busy_timeout         func(db uintptr, ms int) int
-	sqlite3_limit                func(db uintptr, id int, newVal int) int
+	sqlite3_limit                func(db uintptr, id int, newVal int) int32
 	sqlite3_extended_errcode     func(db uintptr) int
+	sqlite3_db_config            func(db uintptr, op int, val int, pOut *int32) int
+	sqlite3_trace_v2             func(db uintptr, mask uint32, callback uintptr, ctx uintptr) int
+	sqlite3_sql                  func(stmt uintptr) uintptr
+	sqlite3_free                 func(ptr uintptr)
+	sqlite3_wal_checkpoint_v2    func(db uintptr, zDb uintptr, eMode int, pnLog *int32, pnCkpt *int32) int
+	sqlite3_wal_autocheckpoint   func(db uintptr, n int) int
+	sqlite3_wal_hook             func(db uintptr, callback uintptr, arg uintptr) uintptr
+	sqlite3_set_authorizer       func(db uintptr, callback uintptr, arg uintptr) int
+	sqlite3_create_collation_v2  func(db uintptr, zName uintptr, eTextRep int, pArg uintptr, xCompare uintptr, xDestroy uintptr) int
+	sqlite3_libversion           func() uintptr
+	sqlite3_libversion_number    func() int
+	sqlite3_compileoption_get    func(n int) uintptr
+	sqlite3_progress_handler     func(db uintptr, n int, callback uintptr, arg uintptr)
+	sqlite3_db_status            func(db uintptr, op int, pCur *int32, pHiwtr *int32, resetFlg int) int
+	sqlite3_status64             func(op int, pCurrent *int64, pHighwater *int64, resetFlag int) int
+	sqlite3_soft_heap_limit64    func(n int64) int64
+	sqlite3_threadsafe           func() int
+	sqlite3_memory_used          func() int64
+	sqlite3_memory_highwater     func(resetFlag int) int64
+	sqlite3_value_type           func(value uintptr) int
+	sqlite3_value_int64          func(value uintptr) int64
+	sqlite3_value_double         func(value uintptr) float64
+	sqlite3_value_text           func(value uintptr) uintptr
+	sqlite3_value_blob           func(value uintptr) uintptr
+	sqlite3_value_bytes          func(value uintptr) int
+	sqlite3_create_function_v2   func(db uintptr, zName uintptr, nArg int, eTextRep int, pApp uintptr, xFunc uintptr, xStep uintptr, xFinal uintptr, xDestroy uintptr) int
+	sqlite3_user_data            func(ctx uintptr) uintptr
+	sqlite3_result_null          func(ctx uintptr)
+	sqlite3_result_int64         func(ctx uintptr, value int64)
+	sqlite3_result_double        func(ctx uintptr, value float64)
+	sqlite3_result_text          func(ctx uintptr, text uintptr, n int, destructor uintptr)
+	sqlite3_result_blob          func(ctx uintptr, blob uintptr, n int, destructor uintptr)
+	sqlite3_result_zeroblob      func(ctx uintptr, n int)
+	sqlite3_result_error         func(ctx uintptr, msg uintptr, n int)
+	sqlite3_result_value         func(ctx uintptr, value uintptr)
+
+	// Optional functions, nil when the loaded library was built without them.
+	sqlite3_stmt_scanstatus       func(stmt uintptr, idx int, op int, pOut unsafe.Pointer) int
+	sqlite3_stmt_scanstatus_reset func(stmt uintptr)
+	sqlite3_load_extension        func(db uintptr, zFile uintptr, zProc uintptr, pzErrMsg *uintptr) int
+	sqlite3_enable_load_extension func(db uintptr, onoff int) int
+	sqlite3_expanded_sql          func(stmt uintptr) uintptr
+	sqlite3_txn_state             func(db uintptr, zSchema uintptr) int32
+	sqlite3_column_database_name  func(stmt uintptr, n int) uintptr
+	sqlite3_column_table_name     func(stmt uintptr, n int) uintptr
+	sqlite3_column_origin_name    func(stmt uintptr, n int) uintptr
+	sqlite3_preupdate_hook        func(db uintptr, callback uintptr, arg uintptr) uintptr
+	sqlite3_preupdate_old         func(db uintptr, i int, ppValue *uintptr) int
+	sqlite3_preupdate_new         func(db uintptr, i int, ppValue *uintptr) int
+	sqlite3_preupdate_count       func(db uintptr) int
+	sqlite3_table_column_metadata func(db uintptr, zDbName uintptr, zTableName uintptr, zColumnName uintptr, pzDataType *uintptr, pzCollSeq *uintptr, pNotNull *int32, pPrimaryKey *int32, pAutoinc *int32) int
+	sqlite3_changes64             func(db uintptr) int64
+	sqlite3_bind_pointer          func(stmt uintptr, idx int, ptr unsafe.Pointer, zType uintptr, destructor uintptr) int
+	sqlite3_total_changes64       func(db uintptr) int64
 )
 
 func loadSQLite3() error {
-	initOnce.Do(func() {
-		initErr = loadLibrary()
-	})
-	return initErr
+	if err := openSQLite3(); err != nil {
+		return err
+	}
+	return checkMinimumVersion()
 }
 
-func loadLibrary() error {
-	var libraryNames []string
+// openSQLite3 loads the library without enforcing the minimum version, so
+// diagnostics such as Version still work against an older library. A failed
+// load is retried on the next call, so fixing the search paths recovers.
+func openSQLite3() error {
+	if loaded.Load() {
+		return nil
+	}
+
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if loaded.Load() {
+		return nil
+	}
+	if err := loadLibrary(); err != nil {
+		return err
+	}
+	loaded.Store(true)
+	return nil
+}
+
+// ForceReload loads the SQLite library again from the current search paths,
+// even if it was loaded before. It must not be called while connections are
+// open, as they would continue with functions from the new library.
+func ForceReload() error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
 
+	loaded.Store(false)
+	if err := loadLibrary(); err != nil {
+		return err
+	}
+	loaded.Store(true)
+	return nil
+}
+
+// SetLibraryPaths sets library paths to try, in order, before SQLITE_PATH
+// and the platform defaults. It is safe to call concurrently, and takes
+// effect on the next load: the first connection opened, a retry after a
+// failed load, or ForceReload.
+func SetLibraryPaths(paths ...string) {
+	libraryPathsMu.Lock()
+	defer libraryPathsMu.Unlock()
+
+	libraryPaths = append([]string(nil), paths...)
+}
+
+// searchPaths returns every library path loadLibrary tries, in order.
+func searchPaths() []string {
+	libraryPathsMu.Lock()
+	names := append([]string(nil), libraryPaths...)
+	libraryPathsMu.Unlock()
+
+	// SQLITE_PATH may list several paths separated by the OS path list
+	// separator (':' on Unix, ';' on Windows).
 	if path := os.Getenv("SQLITE_PATH"); path != "" {
-		libraryNames = append(libraryNames, path)
+		names = append(names, filepath.SplitList(path)...)
 	}
 
+	return append(names, platformLibraryPaths...)
+}
+
+// platformLibraryPaths lists the default library locations for the current
+// platform. It is a variable so tests can simulate a missing library.
+var platformLibraryPaths = defaultLibraryPaths()
+
+func defaultLibraryPaths() []string {
 	switch runtime.GOOS {
 	case "darwin":
-		libraryNames = append(libraryNames,
+		return []string{
 			"libsqlite3.dylib",
 			"libsqlite3.0.dylib",
 			"/usr/lib/libsqlite3.dylib",
 			"/opt/homebrew/lib/libsqlite3.dylib",
 			"/usr/local/lib/libsqlite3.dylib",
-		)
+		}
 	case "linux":
-		libraryNames = append(libraryNames,
+		return []string{
 			"libsqlite3.so",
 			"libsqlite3.so.0",
 			"/usr/lib/x86_64-linux-gnu/libsqlite3.so.0",
 			"/usr/lib/libsqlite3.so.0",
 			"/usr/lib64/libsqlite3.so.0",
-		)
+		}
 	case "windows":
-		libraryNames = append(libraryNames,
+		return []string{
 			"sqlite3.dll",
 			"libsqlite3.dll",
 			"libsqlite3-0.dll",
 			"C:\\Windows\\System32\\sqlite3.dll",
-		)
+		}
+	}
+	return nil
+}
+
+func loadLibrary() error {
+	switch runtime.GOOS {
+	case "darwin", "linux", "windows":
 	default:
 		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
 
 	var loadErrors []string
-	for _, name := range libraryNames {
+	for _, name := range searchPaths() {
+		if name == "" {
+			continue
+		}
 		lib, err := purego.Dlopen(name, purego.RTLD_NOW|purego.RTLD_GLOBAL)
 		if err == nil {
 			libsqlite3 = lib
+			libraryPath = name
 			if err := registerFunctions(); err != nil {
 				return fmt.Errorf("failed to register functions from %s: %w", name, err)
 			}
@@ -172,6 +506,7 @@ func registerFunctions() error {
 	purego.RegisterLibFunc(&sqlite3_step, libsqlite3, "sqlite3_step")
 	purego.RegisterLibFunc(&sqlite3_finalize, libsqlite3, "sqlite3_finalize")
 	purego.RegisterLibFunc(&sqlite3_reset, libsqlite3, "sqlite3_reset")
+	purego.RegisterLibFunc(&sqlite3_clear_bindings, libsqlite3, "sqlite3_clear_bindings")
 	purego.RegisterLibFunc(&sqlite3_column_count, libsqlite3, "sqlite3_column_count")
 	purego.RegisterLibFunc(&sqlite3_column_name, libsqlite3, "sqlite3_column_name")
 	purego.RegisterLibFunc(&sqlite3_column_decltype, libsqlite3, "sqlite3_column_decltype")
@@ -182,13 +517,19 @@ func registerFunctions() error {
 	purego.RegisterLibFunc(&sqlite3_column_blob, libsqlite3, "sqlite3_column_blob")
 	purego.RegisterLibFunc(&sqlite3_column_bytes, libsqlite3, "sqlite3_column_bytes")
 	purego.RegisterLibFunc(&sqlite3_bind_parameter_count, libsqlite3, "sqlite3_bind_parameter_count")
+	purego.RegisterLibFunc(&sqlite3_bind_parameter_index, libsqlite3, "sqlite3_bind_parameter_index")
+	purego.RegisterLibFunc(&sqlite3_bind_parameter_name, libsqlite3, "sqlite3_bind_parameter_name")
+	purego.RegisterLibFunc(&sqlite3_stmt_readonly, libsqlite3, "sqlite3_stmt_readonly")
 	purego.RegisterLibFunc(&sqlite3_bind_null, libsqlite3, "sqlite3_bind_null")
 	purego.RegisterLibFunc(&sqlite3_bind_int64, libsqlite3, "sqlite3_bind_int64")
 	purego.RegisterLibFunc(&sqlite3_bind_double, libsqlite3, "sqlite3_bind_double")
 	purego.RegisterLibFunc(&sqlite3_bind_text, libsqlite3, "sqlite3_bind_text")
 	purego.RegisterLibFunc(&sqlite3_bind_blob, libsqlite3, "sqlite3_bind_blob")
+	purego.RegisterLibFunc(&sqlite3_bind_zeroblob, libsqlite3, "sqlite3_bind_zeroblob")
 	purego.RegisterLibFunc(&sqlite3_last_insert_rowid, libsqlite3, "sqlite3_last_insert_rowid")
 	purego.RegisterLibFunc(&sqlite3_changes, libsqlite3, "sqlite3_changes")
+	purego.RegisterLibFunc(&sqlite3_total_changes, libsqlite3, "sqlite3_total_changes")
+	purego.RegisterLibFunc(&sqlite3_get_autocommit, libsqlite3, "sqlite3_get_autocommit")
 	purego.RegisterLibFunc(&sqlite3_errmsg, libsqlite3, "sqlite3_errmsg")
 	purego.RegisterLibFunc(&sqlite3_errcode, libsqlite3, "sqlite3_errcode")
 	purego.RegisterLibFunc(&sqlite3_exec, libsqlite3, "sqlite3_exec")
@@ -197,5 +538,68 @@ func registerFunctions() error {
 	purego.RegisterLibFunc(&sqlite3_busy_timeout, libsqlite3, "sqlite3_busy_timeout")
 	purego.RegisterLibFunc(&sqlite3_limit, libsqlite3, "sqlite3_limit")
 	purego.RegisterLibFunc(&sqlite3_extended_errcode, libsqlite3, "sqlite3_extended_errcode")
+	purego.RegisterLibFunc(&sqlite3_db_config, libsqlite3, "sqlite3_db_config")
+	purego.RegisterLibFunc(&sqlite3_trace_v2, libsqlite3, "sqlite3_trace_v2")
+	purego.RegisterLibFunc(&sqlite3_sql, libsqlite3, "sqlite3_sql")
+	purego.RegisterLibFunc(&sqlite3_free, libsqlite3, "sqlite3_free")
+	purego.RegisterLibFunc(&sqlite3_wal_checkpoint_v2, libsqlite3, "sqlite3_wal_checkpoint_v2")
+	purego.RegisterLibFunc(&sqlite3_wal_autocheckpoint, libsqlite3, "sqlite3_wal_autocheckpoint")
+	purego.RegisterLibFunc(&sqlite3_wal_hook, libsqlite3, "sqlite3_wal_hook")
+	purego.RegisterLibFunc(&sqlite3_set_authorizer, libsqlite3, "sqlite3_set_authorizer")
+	purego.RegisterLibFunc(&sqlite3_create_collation_v2, libsqlite3, "sqlite3_create_collation_v2")
+	purego.RegisterLibFunc(&sqlite3_libversion, libsqlite3, "sqlite3_libversion")
+	purego.RegisterLibFunc(&sqlite3_libversion_number, libsqlite3, "sqlite3_libversion_number")
+	purego.RegisterLibFunc(&sqlite3_compileoption_get, libsqlite3, "sqlite3_compileoption_get")
+	purego.RegisterLibFunc(&sqlite3_progress_handler, libsqlite3, "sqlite3_progress_handler")
+	purego.RegisterLibFunc(&sqlite3_db_status, libsqlite3, "sqlite3_db_status")
+	purego.RegisterLibFunc(&sqlite3_status64, libsqlite3, "sqlite3_status64")
+	purego.RegisterLibFunc(&sqlite3_soft_heap_limit64, libsqlite3, "sqlite3_soft_heap_limit64")
+	purego.RegisterLibFunc(&sqlite3_threadsafe, libsqlite3, "sqlite3_threadsafe")
+	purego.RegisterLibFunc(&sqlite3_memory_used, libsqlite3, "sqlite3_memory_used")
+	purego.RegisterLibFunc(&sqlite3_memory_highwater, libsqlite3, "sqlite3_memory_highwater")
+	purego.RegisterLibFunc(&sqlite3_value_type, libsqlite3, "sqlite3_value_type")
+	purego.RegisterLibFunc(&sqlite3_value_int64, libsqlite3, "sqlite3_value_int64")
+	purego.RegisterLibFunc(&sqlite3_value_double, libsqlite3, "sqlite3_value_double")
+	purego.RegisterLibFunc(&sqlite3_value_text, libsqlite3, "sqlite3_value_text")
+	purego.RegisterLibFunc(&sqlite3_value_blob, libsqlite3, "sqlite3_value_blob")
+	purego.RegisterLibFunc(&sqlite3_value_bytes, libsqlite3, "sqlite3_value_bytes")
+	purego.RegisterLibFunc(&sqlite3_create_function_v2, libsqlite3, "sqlite3_create_function_v2")
+	purego.RegisterLibFunc(&sqlite3_user_data, libsqlite3, "sqlite3_user_data")
+	purego.RegisterLibFunc(&sqlite3_result_null, libsqlite3, "sqlite3_result_null")
+	purego.RegisterLibFunc(&sqlite3_result_int64, libsqlite3, "sqlite3_result_int64")
+	purego.RegisterLibFunc(&sqlite3_result_double, libsqlite3, "sqlite3_result_double")
+	purego.RegisterLibFunc(&sqlite3_result_text, libsqlite3, "sqlite3_result_text")
+	purego.RegisterLibFunc(&sqlite3_result_blob, libsqlite3, "sqlite3_result_blob")
+	purego.RegisterLibFunc(&sqlite3_result_zeroblob, libsqlite3, "sqlite3_result_zeroblob")
+	purego.RegisterLibFunc(&sqlite3_result_error, libsqlite3, "sqlite3_result_error")
+	purego.RegisterLibFunc(&sqlite3_result_value, libsqlite3, "sqlite3_result_value")
+
+	registerOptional(&sqlite3_stmt_scanstatus, "sqlite3_stmt_scanstatus")
+	registerOptional(&sqlite3_stmt_scanstatus_reset, "sqlite3_stmt_scanstatus_reset")
+	registerOptional(&sqlite3_load_extension, "sqlite3_load_extension")
+	registerOptional(&sqlite3_enable_load_extension, "sqlite3_enable_load_extension")
+	registerOptional(&sqlite3_expanded_sql, "sqlite3_expanded_sql")
+	registerOptional(&sqlite3_txn_state, "sqlite3_txn_state")
+	registerOptional(&sqlite3_column_database_name, "sqlite3_column_database_name")
+	registerOptional(&sqlite3_column_table_name, "sqlite3_column_table_name")
+	registerOptional(&sqlite3_column_origin_name, "sqlite3_column_origin_name")
+	registerOptional(&sqlite3_table_column_metadata, "sqlite3_table_column_metadata")
+	registerOptional(&sqlite3_preupdate_hook, "sqlite3_preupdate_hook")
+	registerOptional(&sqlite3_preupdate_old, "sqlite3_preupdate_old")
+	registerOptional(&sqlite3_preupdate_new, "sqlite3_preupdate_new")
+	registerOptional(&sqlite3_preupdate_count, "sqlite3_preupdate_count")
+	registerOptional(&sqlite3_changes64, "sqlite3_changes64")
+	registerOptional(&sqlite3_bind_pointer, "sqlite3_bind_pointer")
+	registerOptional(&sqlite3_total_changes64, "sqlite3_total_changes64")
 	return nil
 }
+
+// registerOptional binds fptr only when the library exports name, setting it
+// to nil for features that depend on compile-time options.
+func registerOptional(fptr any, name string) {
+	if _, err := purego.Dlsym(libsqlite3, name); err != nil {
+		reflect.ValueOf(fptr).Elem().SetZero()
+		return
+	}
+	purego.RegisterLibFunc(fptr, libsqlite3, name)
+}
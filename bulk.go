@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BulkInsertValues inserts rows into table with multi-row
+// INSERT INTO table (columns) VALUES (?, ...), (?, ...) statements inside a
+// single transaction. Each statement holds at most chunkSize rows, and fewer
+// if needed to stay under the connection's SQLITE_LIMIT_VARIABLE_NUMBER. A
+// chunkSize of 0 or less uses as many rows as the limit allows.
+func BulkInsertValues(db *sql.DB, table string, columns []string, rows [][]any, chunkSize int) error {
+	if err := checkBatch(columns, rows); err != nil || len(rows) == 0 {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var limit int
+	err = conn.Raw(func(driverConn any) error {
+		limit = driverConn.(*Conn).SetLimit(SQLITE_LIMIT_VARIABLE_NUMBER, -1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	maxRows, err := batchRows(limit, len(columns))
+	if err != nil {
+		return err
+	}
+	if chunkSize <= 0 || chunkSize > maxRows {
+		chunkSize = maxRows
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := make([]any, 0, chunkSize*len(columns))
+	for start := 0; start < len(rows); start += chunkSize {
+		chunk := rows[start:min(start+chunkSize, len(rows))]
+
+		args = args[:0]
+		for _, row := range chunk {
+			args = append(args, row...)
+		}
+
+		if _, err := tx.ExecContext(ctx, insertValuesSQL(table, columns, len(chunk)), args...); err != nil {
+			return fmt.Errorf("bulk insert of rows %d-%d failed: %w", start, start+len(chunk)-1, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// InsertBatch inserts rows into table like BulkInsertValues, but directly on
+// this connection. Each chunk of rows is bound to a single prepared multi-row
+// INSERT, sized to stay under SQLITE_LIMIT_VARIABLE_NUMBER. The whole batch
+// runs inside a savepoint, so it is atomic on its own and nests inside an
+// open transaction. It returns the number of rows inserted.
+func (c *Conn) InsertBatch(table string, columns []string, rows [][]any) (int64, error) {
+	if err := checkBatch(columns, rows); err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	maxRows, err := batchRows(c.SetLimit(SQLITE_LIMIT_VARIABLE_NUMBER, -1), len(columns))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.Savepoint("insert_batch"); err != nil {
+		return 0, err
+	}
+	n, err := c.insertBatch(table, columns, rows, maxRows)
+	if err != nil {
+		c.RollbackSavepoint("insert_batch")
+		c.ReleaseSavepoint("insert_batch")
+		return 0, err
+	}
+	if err := c.ReleaseSavepoint("insert_batch"); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (c *Conn) insertBatch(table string, columns []string, rows [][]any, maxRows int) (int64, error) {
+	ctx := context.Background()
+	args := make([]driver.NamedValue, 0, min(maxRows, len(rows))*len(columns))
+
+	var stmt *Stmt
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+
+	var total int64
+	for start := 0; start < len(rows); start += maxRows {
+		chunk := rows[start:min(start+maxRows, len(rows))]
+
+		// Full chunks share one statement; only the final, shorter chunk
+		// needs a statement of its own.
+		if stmt == nil || len(chunk) != maxRows {
+			if stmt != nil {
+				stmt.Close()
+			}
+			var err error
+			if stmt, _, err = c.prepare(insertValuesSQL(table, columns, len(chunk))); err != nil {
+				stmt = nil
+				return 0, err
+			}
+		}
+
+		args = args[:0]
+		for _, row := range chunk {
+			for _, v := range row {
+				args = append(args, driver.NamedValue{Ordinal: len(args) + 1, Value: v})
+			}
+		}
+
+		result, err := stmt.ExecContext(ctx, args)
+		if err != nil {
+			return 0, fmt.Errorf("insert batch of rows %d-%d failed: %w", start, start+len(chunk)-1, err)
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+
+	return total, nil
+}
+
+func checkBatch(columns []string, rows [][]any) error {
+	if len(columns) == 0 {
+		return errors.New("bulk insert needs at least one column")
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf("row %d has %d values, expected %d", i, len(row), len(columns))
+		}
+	}
+	return nil
+}
+
+// batchRows returns how many rows of the given width fit in one statement
+// under the variable limit.
+func batchRows(limit, width int) (int, error) {
+	if limit/width == 0 {
+		return 0, fmt.Errorf("%d columns exceed the variable limit of %d", width, limit)
+	}
+	return limit / width, nil
+}
+
+// insertValuesSQL returns INSERT INTO table (columns) VALUES followed by n
+// placeholder tuples.
+func insertValuesSQL(table string, columns []string, n int) string {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdentifier(col)
+	}
+	tuple := "(" + strings.Repeat("?, ", len(columns)-1) + "?)"
+
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + quoteIdentifier(table) + " (" + strings.Join(quoted, ", ") + ") VALUES ")
+	for i := range n {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(tuple)
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+// defaultBusyBackoff is the first retry delay of _retry_busy when
+// _retry_backoff is not given.
+const defaultBusyBackoff = 10 * time.Millisecond
+
+// SetBusyRetry makes statements that fail with SQLITE_BUSY or SQLITE_LOCKED
+// before producing a result retry up to attempts more times, waiting backoff
+// before the first retry and doubling the wait after each one. This covers
+// lock conflicts the busy timeout cannot, such as SQLITE_LOCKED and busy
+// errors returned without waiting. Statements inside an explicit transaction
+// are never retried, since the transaction may need to be rolled back
+// first. An attempts of zero or less disables retrying.
+func (c *Conn) SetBusyRetry(attempts int, backoff time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.busyRetries = max(attempts, 0)
+	c.busyBackoff = backoff
+}
+
+// step steps the statement, retrying as configured by SetBusyRetry. It must
+// only be called for the first step, before the statement has had effects.
+func (s *Stmt) step(ctx context.Context) int {
+	s.track()
+	return s.conn.retryBusy(ctx, s.conn.stepFirst(s.stmt), func() int {
+		// Reset keeps the bindings.
+		sqlite3_reset(s.stmt)
+		return s.conn.stepFirst(s.stmt)
+	})
+}
+
+// retryBusy calls retry while rc is a busy or locked error in autocommit
+// mode, up to the configured number of attempts, and returns the last result
+// code. A failed statement in autocommit mode rolls back its implicit
+// transaction, so running it again is safe.
+func (c *Conn) retryBusy(ctx context.Context, rc int, retry func() int) int {
+	backoff := c.busyBackoff
+	for attempt := 0; attempt < c.busyRetries; attempt++ {
+		if code := rc & 0xff; code != SQLITE_BUSY && code != SQLITE_LOCKED {
+			break
+		}
+		if sqlite3_get_autocommit(c.db) == 0 {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return rc
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		rc = retry()
+	}
+
+	return rc
+}
+
+type busyTimeoutKey struct{}
+
+// ContextWithBusyTimeout returns a copy of ctx that makes statements run with
+// it wait up to d for a locked database instead of the connection's busy
+// timeout, which is restored once each statement has taken its locks. It
+// differs from the WithBusyTimeout option, which sets the default for every
+// connection of a connector. A zero or negative d fails at once.
+func ContextWithBusyTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, busyTimeoutKey{}, d)
+}
+
+// overrideBusyTimeout applies the busy timeout of ctx, if any, and returns a
+// function restoring the previous one. The caller must hold c.mu.
+func (c *Conn) overrideBusyTimeout(ctx context.Context) (restore func()) {
+	d, ok := ctx.Value(busyTimeoutKey{}).(time.Duration)
+	if !ok {
+		return func() {}
+	}
+
+	// SQLite has no getter for the timeout, and PRAGMA busy_timeout may have
+	// changed it, so read it back through the pragma.
+	previous := c.busyTimeoutMillis()
+	sqlite3_busy_timeout(c.db, durationMillis(d))
+	return func() {
+		sqlite3_busy_timeout(c.db, previous)
+	}
+}
+
+// busyTimeoutMillis returns the connection's busy timeout. The caller must
+// hold c.mu.
+func (c *Conn) busyTimeoutMillis() int {
+	queryPtr, pinner := cString("PRAGMA busy_timeout")
+	defer unpin(pinner)
+
+	var stmt uintptr
+	if sqlite3_prepare_v2(c.db, queryPtr, -1, &stmt, nil) != SQLITE_OK {
+		return 0
+	}
+	defer sqlite3_finalize(stmt)
+
+	defer c.endQuery()
+	if c.stepFirst(stmt) != SQLITE_ROW {
+		return 0
+	}
+	return int(sqlite3_column_int64(stmt, 0))
+}
@@ -0,0 +1,326 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// Callbacks created by purego can never be released, so the driver creates a
+// single trampoline per hook type and routes each invocation to its
+// connection through the handle SQLite hands back as user data.
+var (
+	callbacksOnce sync.Once
+
+	traceCallback uintptr
+	walCallback   uintptr
+	authCallback  uintptr
+
+	preUpdateCallback uintptr
+	progressCallback  uintptr
+
+	collationCallback        uintptr
+	collationDestroyCallback uintptr
+
+	functionCallback        uintptr
+	functionDestroyCallback uintptr
+
+	tokenizerCreateCallback  uintptr
+	tokenizerDeleteCallback  uintptr
+	tokenizeCallback         uintptr
+	tokenizerDestroyCallback uintptr
+
+	connHandles    = NewThreadSafeMap[uintptr, *Conn]()
+	nextConnHandle atomic.Uintptr
+
+	// Collations are owned by SQLite rather than a connection, so they get
+	// their own handles, released through the destroy callback.
+	collationHandles    = NewThreadSafeMap[uintptr, func(a, b string) int]()
+	nextCollationHandle atomic.Uintptr
+
+	// Functions share the collation scheme: SQLite owns them and releases
+	// their handles through the destroy callback.
+	functionHandles    = NewThreadSafeMap[uintptr, Function]()
+	nextFunctionHandle atomic.Uintptr
+
+	// FTS5 owns tokenizer factories like functions. Each tokenizer it
+	// creates from one gets a handle of its own, which FTS5 uses as the
+	// Fts5Tokenizer pointer.
+	tokenizerFactories  = NewThreadSafeMap[uintptr, TokenizerFactory]()
+	tokenizerInstances  = NewThreadSafeMap[uintptr, Tokenizer]()
+	nextTokenizerHandle atomic.Uintptr
+)
+
+func initCallbacks() {
+	callbacksOnce.Do(func() {
+		traceCallback = purego.NewCallback(traceTrampoline)
+		walCallback = purego.NewCallback(walTrampoline)
+		authCallback = purego.NewCallback(authTrampoline)
+		preUpdateCallback = purego.NewCallback(preUpdateTrampoline)
+		progressCallback = purego.NewCallback(progressTrampoline)
+		collationCallback = purego.NewCallback(collationTrampoline)
+		collationDestroyCallback = purego.NewCallback(collationDestroyTrampoline)
+		functionCallback = purego.NewCallback(functionTrampoline)
+		functionDestroyCallback = purego.NewCallback(functionDestroyTrampoline)
+		tokenizerCreateCallback = purego.NewCallback(tokenizerCreateTrampoline)
+		tokenizerDeleteCallback = purego.NewCallback(tokenizerDeleteTrampoline)
+		tokenizeCallback = purego.NewCallback(tokenizeTrampoline)
+		tokenizerDestroyCallback = purego.NewCallback(tokenizerDestroyTrampoline)
+
+		fts5TokenizerMethods.create = tokenizerCreateCallback
+		fts5TokenizerMethods.delete = tokenizerDeleteCallback
+		fts5TokenizerMethods.tokenize = tokenizeCallback
+	})
+}
+
+func registerConn(c *Conn) {
+	c.handle = nextConnHandle.Add(1)
+	connHandles.Store(c.handle, c)
+}
+
+func unregisterConn(c *Conn) {
+	connHandles.Delete(c.handle)
+}
+
+func traceTrampoline(mask uint32, handle, p, x uintptr) int32 {
+	c, ok := connHandles.Load(handle)
+	if !ok {
+		return 0
+	}
+
+	switch mask {
+	case SQLITE_TRACE_STMT:
+		if c.trace != nil {
+			c.trace(statementSQL(p, x))
+		}
+	case SQLITE_TRACE_PROFILE:
+		if c.profile != nil {
+			nanos := *(*int64)(cPointer(x))
+			c.profile(statementSQL(p, sqlite3_sql(p)), nanos)
+		}
+	}
+
+	return 0
+}
+
+// statementSQL returns the SQL of stmt with bound parameters expanded, or
+// the text at fallback when the library cannot expand it.
+func statementSQL(stmt, fallback uintptr) string {
+	if sqlite3_expanded_sql != nil {
+		if expanded := sqlite3_expanded_sql(stmt); expanded != 0 {
+			defer sqlite3_free(expanded)
+			return goString(expanded)
+		}
+	}
+	return goString(fallback)
+}
+
+func walTrampoline(handle, db, dbName uintptr, pages int32) int32 {
+	c, ok := connHandles.Load(handle)
+	if !ok || c.walHook == nil {
+		return SQLITE_OK
+	}
+
+	if err := c.walHook(goString(dbName), int(pages)); err != nil {
+		return SQLITE_ERROR
+	}
+
+	return SQLITE_OK
+}
+
+func authTrampoline(handle uintptr, action int32, arg1, arg2, dbName, trigger uintptr) int32 {
+	c, ok := connHandles.Load(handle)
+	if !ok || c.authorizer == nil {
+		return SQLITE_OK
+	}
+
+	return int32(c.authorizer(int(action), goString(arg1), goString(arg2), goString(dbName), goString(trigger)))
+}
+
+func preUpdateTrampoline(handle, db uintptr, op int32, dbName, table uintptr, oldRowid, newRowid int64) {
+	c, ok := connHandles.Load(handle)
+	if !ok || c.preUpdate == nil {
+		return
+	}
+
+	var old, new []driver.Value
+	n := sqlite3_preupdate_count(db)
+	if op == SQLITE_UPDATE || op == SQLITE_DELETE {
+		old = preUpdateValues(db, n, sqlite3_preupdate_old)
+	}
+	if op == SQLITE_UPDATE || op == SQLITE_INSERT {
+		new = preUpdateValues(db, n, sqlite3_preupdate_new)
+	}
+
+	c.preUpdate(int(op), goString(dbName), goString(table), oldRowid, newRowid, old, new)
+}
+
+func preUpdateValues(db uintptr, n int, get func(db uintptr, i int, ppValue *uintptr) int) []driver.Value {
+	values := make([]driver.Value, n)
+	for i := range values {
+		var value uintptr
+		if get(db, i, &value) == SQLITE_OK {
+			values[i] = goValue(value)
+		}
+	}
+	return values
+}
+
+// goValue copies a protected or unprotected sqlite3_value into a Go value.
+func goValue(value uintptr) driver.Value {
+	if value == 0 {
+		return nil
+	}
+
+	switch sqlite3_value_type(value) {
+	case SQLITE_INTEGER:
+		return sqlite3_value_int64(value)
+	case SQLITE_REAL:
+		return sqlite3_value_double(value)
+	case SQLITE_TEXT:
+		text := sqlite3_value_text(value)
+		return goStringN(text, sqlite3_value_bytes(value))
+	case SQLITE_BLOB:
+		blob := sqlite3_value_blob(value)
+		return goBytesN(blob, sqlite3_value_bytes(value))
+	default:
+		return nil
+	}
+}
+
+// progressTrampoline runs every progressInterval virtual machine
+// instructions; a nonzero return interrupts the running statement.
+func progressTrampoline(handle uintptr) int32 {
+	c, ok := connHandles.Load(handle)
+	if !ok {
+		return 0
+	}
+
+	if c.memoryBudget > 0 && c.cacheUsed() > c.memoryBudget {
+		c.budgetExceeded.Store(true)
+		return 1
+	}
+
+	if c.opLimit > 0 && c.opsUsed.Add(c.progressStep) > c.opLimit {
+		c.opLimitHit.Store(true)
+		return 1
+	}
+
+	if deadline := c.deadline.Load(); deadline != 0 && time.Now().UnixNano() > deadline {
+		c.timedOut.Store(true)
+		return 1
+	}
+
+	return 0
+}
+
+func collationTrampoline(handle uintptr, na int32, a uintptr, nb int32, b uintptr) int32 {
+	cmp, ok := collationHandles.Load(handle)
+	if !ok {
+		return 0
+	}
+
+	return int32(cmp(goStringN(a, int(na)), goStringN(b, int(nb))))
+}
+
+func collationDestroyTrampoline(handle uintptr) {
+	collationHandles.Delete(handle)
+}
+
+func functionTrampoline(ctx uintptr, argc int32, argv uintptr) {
+	fn, ok := functionHandles.Load(sqlite3_user_data(ctx))
+	if !ok {
+		sqlite3_result_null(ctx)
+		return
+	}
+
+	args := make([]FuncArg, argc)
+	if argc > 0 {
+		values := unsafe.Slice((*uintptr)(cPointer(argv)), argc)
+		for i, value := range values {
+			args[i] = FuncArg{value: value}
+		}
+	}
+
+	result, err := fn(args)
+	if err == nil {
+		err = setResult(ctx, result)
+	}
+	if err != nil {
+		msg := err.Error()
+		msgPtr, pinner := pinString(msg)
+		sqlite3_result_error(ctx, msgPtr, len(msg))
+		unpin(pinner)
+	}
+}
+
+func functionDestroyTrampoline(handle uintptr) {
+	functionHandles.Delete(handle)
+}
+
+func tokenizerCreateTrampoline(handle, azArg uintptr, nArg int32, ppOut uintptr) int32 {
+	factory, ok := tokenizerFactories.Load(handle)
+	if !ok {
+		return SQLITE_ERROR
+	}
+
+	args := make([]string, nArg)
+	if nArg > 0 {
+		for i, arg := range unsafe.Slice((*uintptr)(cPointer(azArg)), nArg) {
+			args[i] = goString(arg)
+		}
+	}
+
+	tokenizer, err := factory(args)
+	if err != nil {
+		return SQLITE_ERROR
+	}
+
+	instance := nextTokenizerHandle.Add(1)
+	tokenizerInstances.Store(instance, tokenizer)
+	*(*uintptr)(cPointer(ppOut)) = instance
+	return SQLITE_OK
+}
+
+func tokenizerDeleteTrampoline(instance uintptr) {
+	tokenizerInstances.Delete(instance)
+}
+
+func tokenizeTrampoline(instance, pCtx uintptr, flags int32, pText uintptr, nText int32, xToken uintptr) int32 {
+	tokenizer, ok := tokenizerInstances.Load(instance)
+	if !ok {
+		return SQLITE_ERROR
+	}
+
+	rc := int32(SQLITE_OK)
+	emit := func(token string, start, end int) error {
+		tokenPtr, pinner := pinString(token)
+		r1, _, _ := purego.SyscallN(xToken, pCtx, 0, tokenPtr, uintptr(len(token)), uintptr(start), uintptr(end))
+		unpin(pinner)
+
+		if rc = int32(r1); rc != SQLITE_OK {
+			return errors.New(errorString(int(rc)))
+		}
+		return nil
+	}
+
+	err := tokenizer.Tokenize(goStringN(pText, int(nText)), int(flags), emit)
+	if rc != SQLITE_OK {
+		// FTS5 stops some tokenizations early by returning SQLITE_DONE,
+		// which must be handed back unchanged.
+		return rc
+	}
+	if err != nil {
+		return SQLITE_ERROR
+	}
+	return SQLITE_OK
+}
+
+func tokenizerDestroyTrampoline(handle uintptr) {
+	tokenizerFactories.Delete(handle)
+}
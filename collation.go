@@ -0,0 +1,230 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// RegisterCollation registers cmp as a collating sequence named name on this
+// connection. cmp returns a negative number, zero or a positive number when a
+// sorts before, equal to or after b. Registering an existing name replaces it.
+func (c *Conn) RegisterCollation(name string, cmp func(a, b string) int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	return c.registerCollation(name, cmp)
+}
+
+func (c *Conn) registerCollation(name string, cmp func(a, b string) int) error {
+	initCallbacks()
+
+	handle := nextCollationHandle.Add(1)
+	collationHandles.Store(handle, cmp)
+
+	namePtr, pinner := cString(name)
+	defer unpin(pinner)
+
+	rc := sqlite3_create_collation_v2(c.db, namePtr, SQLITE_UTF8, handle, collationCallback, collationDestroyCallback)
+	if rc != SQLITE_OK {
+		// SQLite calls the destroy callback itself only on success.
+		collationHandles.Delete(handle)
+		return fmt.Errorf("create collation failed: %w", c.lastError())
+	}
+
+	return nil
+}
+
+// CollatedQuery rewrites query so that every ORDER BY term without an
+// explicit COLLATE clause sorts with collation. SQLite cannot change a
+// column's default collation after the table is created, so this applies a
+// collation registered with RegisterCollation or Config.Collations to
+// existing queries. Terms inside subqueries and window definitions are
+// rewritten as well.
+func CollatedQuery(collation, query string) string {
+	tokens := tokenizeSQL(query)
+	clause := " COLLATE " + quoteIdentifier(collation)
+
+	var inserts []int
+	for i := 0; i < len(tokens); i++ {
+		if !tokens[i].isWord("ORDER") {
+			continue
+		}
+		next := nextToken(tokens, i)
+		if next < 0 || !tokens[next].isWord("BY") {
+			continue
+		}
+
+		var end int
+		inserts, end = collateTerms(tokens, next+1, inserts)
+		i = end - 1
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, pos := range inserts {
+		b.WriteString(query[last:pos])
+		b.WriteString(clause)
+		last = pos
+	}
+	b.WriteString(query[last:])
+
+	return b.String()
+}
+
+// collateTerms records the insertion point of a COLLATE clause for each
+// ORDER BY term starting at tokens[start] and returns the index of the token
+// that ended the clause.
+func collateTerms(tokens []sqlToken, start int, inserts []int) ([]int, int) {
+	depth := 0
+	insertAt := -1 // Position before ASC, DESC or NULLS, if seen
+	collated := false
+	termEnd := -1 // End of the last significant token in the term
+
+	finishTerm := func() {
+		if !collated && termEnd >= 0 {
+			if insertAt >= 0 {
+				inserts = append(inserts, insertAt)
+			} else {
+				inserts = append(inserts, termEnd)
+			}
+		}
+		insertAt, collated, termEnd = -1, false, -1
+	}
+
+	i := start
+	for ; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind == tokenSpace {
+			continue
+		}
+
+		if depth == 0 {
+			switch {
+			case tok.text == ",":
+				finishTerm()
+				continue
+			case tok.text == ")" || tok.text == ";" || tok.isWord("LIMIT") || tok.isWord("OFFSET") ||
+				tok.isWord("UNION") || tok.isWord("EXCEPT") || tok.isWord("INTERSECT") ||
+				tok.isWord("ROWS") || tok.isWord("RANGE") || tok.isWord("GROUPS"):
+				finishTerm()
+				return inserts, i
+			case tok.isWord("COLLATE"):
+				collated = true
+			case insertAt < 0 && (tok.isWord("ASC") || tok.isWord("DESC") || tok.isWord("NULLS")):
+				insertAt = termEnd
+			}
+		}
+
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if insertAt < 0 {
+			termEnd = tok.end
+		}
+	}
+
+	finishTerm()
+	return inserts, i
+}
+
+type sqlTokenKind int
+
+const (
+	tokenSpace sqlTokenKind = iota
+	tokenWord
+	tokenQuoted
+	tokenSymbol
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+	end  int
+}
+
+func (t sqlToken) isWord(word string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, word)
+}
+
+func nextToken(tokens []sqlToken, i int) int {
+	for i++; i < len(tokens); i++ {
+		if tokens[i].kind != tokenSpace {
+			return i
+		}
+	}
+	return -1
+}
+
+// tokenizeSQL splits query into words, quoted strings and identifiers,
+// single-character symbols, and runs of whitespace or comments.
+func tokenizeSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		start := i
+		kind := tokenSymbol
+		ch := query[i]
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			kind = tokenSpace
+			for i < len(query) && strings.IndexByte(" \t\n\r", query[i]) >= 0 {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "--"):
+			kind = tokenSpace
+			if n := strings.IndexByte(query[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			kind = tokenSpace
+			if n := strings.Index(query[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(query)
+			}
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			kind = tokenQuoted
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			for i++; i < len(query); i++ {
+				if query[i] != closing {
+					continue
+				}
+				// A doubled quote is an escaped quote inside the literal.
+				if closing != ']' && i+1 < len(query) && query[i+1] == closing {
+					i++
+					continue
+				}
+				break
+			}
+			i = min(i+1, len(query))
+		case ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch >= 0x80:
+			kind = tokenWord
+			for i < len(query) {
+				c := query[i]
+				if c != '_' && c != '$' && !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80) {
+					break
+				}
+				i++
+			}
+		default:
+			i++
+		}
+
+		tokens = append(tokens, sqlToken{kind: kind, text: query[start:i], end: i})
+	}
+
+	return tokens
+}
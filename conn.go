@@ -6,16 +6,54 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// Conn is a single SQLite connection. Like any driver.Conn it is used by one
+// goroutine at a time: database/sql never shares it between concurrent
+// callers. Calls into SQLite are additionally serialized by the connection's
+// mutex, including stepping through Rows, so methods reached through
+// sql.Conn.Raw, such as TotalChanges or DBStatus, are safe to call while
+// another goroutine uses the connection. Callbacks such as hooks and
+// collations run while that mutex is held and must not use the connection
+// they were registered on.
 type Conn struct {
 	db     uintptr
+	cfg    *config
 	tx     *Tx
-	stmts  *ThreadSafeMap[uintptr, *Stmt]
+	stmts  ConcurrentMap[uintptr, *Stmt]
+	cache  *stmtCache  // Idle statements for reuse, nil when disabled
 	mu     *sync.Mutex // Only for SQLite API calls and tx management
 	closed atomic.Bool // Atomic for lock-free reads
+	handle uintptr     // Identifies the connection to callback trampolines
+
+	trace    func(sql string)
+	profile  func(sql string, nanos int64)
+	onCommit func()
+	walHook  func(dbName string, pages int) error
+
+	authorizer func(action int, arg1, arg2, dbName, trigger string) int
+	preUpdate  func(op int, db, table string, oldRowid, newRowid int64, old, new []driver.Value)
+
+	memoryBudget   int64        // Page cache bytes allowed, zero for no limit
+	budgetExceeded atomic.Bool  // Set when the progress handler interrupted a statement
+	deadline       atomic.Int64 // Unix nanoseconds the current query must finish by, zero for none
+	timedOut       atomic.Bool  // Set when the progress handler interrupted a query past its deadline
+	opLimit        int64        // Instructions a statement may run, zero for no limit
+	opsUsed        atomic.Int64 // Instructions run by the current statement, counted per progress call
+	opLimitHit     atomic.Bool  // Set when the progress handler interrupted a statement over opLimit
+	progressStep   int64        // Instructions between progress handler calls
+	busyRetries    int          // Retries of statements failing with SQLITE_BUSY or SQLITE_LOCKED
+	busyBackoff    time.Duration
+	active         *ThreadSafeMap[int, ActiveStmt] // Running statements by ID
+	nextActiveID   atomic.Int64
+	killed         atomic.Bool  // Set when KillStatement interrupted the running statements
+	closeMu        sync.RWMutex // Keeps Close from freeing db while KillStatement interrupts it
+	translateError func(*Error) error
 }
 
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
@@ -29,34 +67,114 @@ func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, e
 	default:
 	}
 
+	stmt, _, err := c.prepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt == nil {
+		return nil, errors.New("empty statement")
+	}
+
+	return stmt, nil
+}
+
+// prepare compiles the first statement in query and returns it together
+// with the remaining, uncompiled SQL. The statement is nil when query holds
+// only whitespace or comments.
+func (c *Conn) prepare(query string) (*Stmt, string, error) {
+	return c.prepareContext(context.Background(), query)
+}
+
+// prepareContext is prepare with the busy timeout of ctx, if any, applied
+// while the schema is loaded.
+func (c *Conn) prepareContext(ctx context.Context, query string) (*Stmt, string, error) {
 	if c.closed.Load() {
-		return nil, driver.ErrBadConn
+		return nil, "", driver.ErrBadConn
 	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.cache != nil {
+		if stmt := c.cache.get(query); stmt != nil {
+			stmt.reset()
+			return stmt, stmt.tail, nil
+		}
+	}
+
 	queryPtr, pinner := cString(query)
 	defer unpin(pinner)
 
-	var stmtPtr uintptr
-	rc := sqlite3_prepare_v2(c.db, queryPtr, -1, &stmtPtr, 0)
+	var stmtPtr, tailPtr uintptr
+	prepare := func() int {
+		return sqlite3_prepare_v2(c.db, queryPtr, -1, &stmtPtr, &tailPtr)
+	}
+	// Loading the schema needs a read lock, so preparing can be busy too.
+	restore := c.overrideBusyTimeout(ctx)
+	rc := c.retryBusy(ctx, prepare(), prepare)
+	restore()
 	if rc != SQLITE_OK {
-		return nil, fmt.Errorf("prepare failed: %s", getErrorMessage(c.db))
+		return nil, "", fmt.Errorf("prepare failed: %w", c.lastError())
+	}
+
+	var tail string
+	if tailPtr != 0 {
+		tail = query[tailPtr-queryPtr:]
 	}
 
 	if stmtPtr == 0 {
-		return nil, errors.New("empty statement")
+		return nil, tail, nil
 	}
+	stmtsPrepared.Add(1)
 
 	stmt := &Stmt{
-		conn:  c,
-		stmt:  stmtPtr,
-		query: query,
+		conn:     c,
+		stmt:     stmtPtr,
+		query:    query[:len(query)-len(tail)],
+		tail:     tail,
+		cacheKey: query,
 	}
 
 	c.stmts.Store(stmtPtr, stmt)
-	return stmt, nil
+	return stmt, tail, nil
+}
+
+// emptySQL reports whether query holds no statements, only whitespace,
+// comments and semicolons.
+func emptySQL(query string) bool {
+	for _, tok := range tokenizeSQL(query) {
+		if tok.kind != tokenSpace && tok.text != ";" {
+			return false
+		}
+	}
+	return true
+}
+
+// release offers a statement that is being closed to the statement cache.
+// It reports whether the cache kept it; otherwise the caller finalizes it.
+func (c *Conn) release(s *Stmt) bool {
+	if c.cache == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() || s.stale {
+		return false
+	}
+
+	s.reset()
+	evicted := c.cache.put(s)
+	if evicted == s {
+		return false
+	}
+
+	if evicted != nil {
+		evicted.finalize()
+	}
+	return true
 }
 
 func (c *Conn) Close() error {
@@ -69,8 +187,40 @@ func (c *Conn) Close() error {
 
 	for _, stmt := range c.stmts.Iter() {
 		sqlite3_finalize(stmt.stmt)
+		stmtsFinalized.Add(1)
+		stmt.closed = true
 	}
 	c.stmts.Clear()
+	if c.cache != nil {
+		c.cache.clear()
+	}
+
+	if c.trace != nil || c.profile != nil {
+		sqlite3_trace_v2(c.db, 0, 0, 0)
+		c.trace = nil
+		c.profile = nil
+	}
+	if c.walHook != nil {
+		sqlite3_wal_hook(c.db, 0, 0)
+		c.walHook = nil
+	}
+	if c.authorizer != nil {
+		sqlite3_set_authorizer(c.db, 0, 0)
+		c.authorizer = nil
+	}
+	if c.preUpdate != nil {
+		sqlite3_preupdate_hook(c.db, 0, 0)
+		c.preUpdate = nil
+	}
+	if c.progressNeeded() {
+		sqlite3_progress_handler(c.db, 0, 0, 0)
+		c.memoryBudget = 0
+		c.opLimit = 0
+	}
+	unregisterConn(c)
+
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
 
 	rc := sqlite3_close(c.db)
 	if rc != SQLITE_OK {
@@ -121,12 +271,9 @@ func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 	}
 
 	query := fmt.Sprintf("BEGIN %s", sqliteMode)
-	queryPtr, pinner := cString(query)
-	defer unpin(pinner)
-
-	rc := sqlite3_exec(c.db, queryPtr, 0, 0, 0)
+	rc := c.exec(query)
 	if rc != SQLITE_OK {
-		return nil, fmt.Errorf("begin transaction failed: %s", getErrorMessage(c.db))
+		return nil, fmt.Errorf("begin transaction failed: %w", c.lastError())
 	}
 
 	tx := &Tx{
@@ -138,6 +285,9 @@ func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 	return tx, nil
 }
 
+// ExecContext runs query, which may hold several statements, to completion.
+// Rows produced by a RETURNING clause are discarded whether or not args are
+// given; use QueryContext to read them.
 func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	select {
 	case <-ctx.Done():
@@ -145,17 +295,29 @@ func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.Name
 	default:
 	}
 
-	if len(args) == 0 {
+	// sqlite3_exec cannot tell which statement of a script was busy, so
+	// retrying or overriding the busy timeout runs each statement separately.
+	if len(args) == 0 && c.busyRetries == 0 && ctx.Value(busyTimeoutKey{}) == nil {
 		return c.execDirect(query)
 	}
 
-	stmt, err := c.PrepareContext(ctx, query)
+	return c.execMulti(ctx, query, args)
+}
+
+// execMulti executes every statement in query in order. Positional
+// arguments are consumed from args as each statement needs them. The result
+// is that of the last statement.
+func (c *Conn) execMulti(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	results, err := c.execScript(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	if len(results) == 0 {
+		return &Result{}, nil
+	}
 
-	return stmt.(*Stmt).ExecContext(ctx, args)
+	last := results[len(results)-1]
+	return &Result{lastInsertID: last.LastInsertID, rowsAffected: last.RowsAffected}, nil
 }
 
 func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
@@ -165,18 +327,42 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 	default:
 	}
 
-	stmt, err := c.PrepareContext(ctx, query)
+	stmt, tail, err := c.prepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	if stmt == nil {
+		return nil, errors.New("empty statement")
+	}
+
+	// Further statements become further result sets, each taking its
+	// arguments as in a script.
+	var rest []driver.NamedValue
+	if strings.TrimSpace(tail) != "" {
+		named := hasNamedArgs(args)
+		if args, rest, err = stmt.takeArgs(args, named); err != nil {
+			stmt.Close()
+			return nil, err
+		}
+		if !named && len(rest) > 0 && emptySQL(tail) {
+			stmt.Close()
+			return nil, fmt.Errorf("%d arguments left unused", len(rest))
+		}
+	}
 
-	rows, err := stmt.(*Stmt).QueryContext(ctx, args)
+	rows, err := stmt.QueryContext(ctx, args)
 	if err != nil {
 		stmt.Close()
 		return nil, err
 	}
 
-	return rows, nil
+	// The statement was prepared just for this query, so the rows own it
+	// and finalize it once they are closed.
+	r := rows.(*Rows)
+	r.ownsStmt = true
+	r.tail = tail
+	r.args = rest
+	return r, nil
 }
 
 func (c *Conn) Ping(ctx context.Context) error {
@@ -212,12 +398,254 @@ func (c *Conn) ResetSession(ctx context.Context) error {
 	}
 
 	for _, stmt := range c.stmts.Iter() {
-		sqlite3_reset(stmt.stmt)
+		stmt.reset()
+	}
+
+	return nil
+}
+
+// LastInsertRowID returns the rowid of the most recent successful INSERT on
+// this connection, or 0 if there has been none.
+func (c *Conn) LastInsertRowID() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return 0
+	}
+	return sqlite3_last_insert_rowid(c.db)
+}
+
+// Changes returns the number of rows modified by the most recently completed
+// INSERT, UPDATE or DELETE on this connection.
+func (c *Conn) Changes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return 0
+	}
+	return int(changes(c.db))
+}
+
+// Handle returns the underlying sqlite3* database handle, or 0 once the
+// connection is closed, for calling C functions the driver does not wrap.
+// The driver does not know about anything done through the handle: closing
+// it, changing hooks or handlers the driver installs, or using it outside
+// sql.Conn.Raw while another goroutine runs queries on the connection can
+// corrupt the connection's state or crash the process.
+func (c *Conn) Handle() uintptr {
+	if c.closed.Load() {
+		return 0
+	}
+	return c.db
+}
+
+// TotalChanges returns the number of rows modified by INSERT, UPDATE and
+// DELETE statements since the connection was opened.
+func (c *Conn) TotalChanges() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return 0
+	}
+	return int(totalChanges(c.db))
+}
+
+// changes returns sqlite3_changes64, falling back to the 32-bit
+// sqlite3_changes on libraries older than 3.37.
+func changes(db uintptr) int64 {
+	if sqlite3_changes64 != nil {
+		return sqlite3_changes64(db)
+	}
+	return int64(sqlite3_changes(db))
+}
+
+// totalChanges is the sqlite3_total_changes counterpart of changes.
+func totalChanges(db uintptr) int64 {
+	if sqlite3_total_changes64 != nil {
+		return sqlite3_total_changes64(db)
+	}
+	return int64(sqlite3_total_changes(db))
+}
+
+// InAutocommit reports whether the connection is in autocommit mode, that is,
+// no transaction has been started with BEGIN or a savepoint. It returns
+// false on a closed connection.
+func (c *Conn) InAutocommit() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return false
+	}
+
+	return sqlite3_get_autocommit(c.db) != 0
+}
+
+// TxnState reports the transaction state of schema ("main", "temp" or an
+// attached database name) as SQLITE_TXN_NONE, SQLITE_TXN_READ or
+// SQLITE_TXN_WRITE. An empty schema reports the highest state across all
+// schemas. It returns -1 for an unknown schema, a closed connection, or when
+// SQLite is older than 3.34.
+func (c *Conn) TxnState(schema string) int {
+	if sqlite3_txn_state == nil {
+		return -1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return -1
+	}
+
+	schemaPtr, pinner := cString(schema)
+	defer unpin(pinner)
+
+	return int(sqlite3_txn_state(c.db, schemaPtr))
+}
+
+// SetLimit sets the run-time limit id, one of the SQLITE_LIMIT_* constants, to
+// newVal and returns its previous value. A negative newVal leaves the limit
+// unchanged. Values above the compile-time maximum are truncated to it.
+// It returns -1 if id is unknown or the connection is closed.
+func (c *Conn) SetLimit(id, newVal int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return -1
+	}
+	return int(sqlite3_limit(c.db, id, newVal))
+}
+
+// SetTriggersEnabled enables or disables the firing of triggers on this
+// connection.
+func (c *Conn) SetTriggersEnabled(enabled bool) error {
+	_, err := c.dbConfig(SQLITE_DBCONFIG_ENABLE_TRIGGER, enabled)
+	return err
+}
+
+// SetViewsEnabled enables or disables the use of views on this connection.
+func (c *Conn) SetViewsEnabled(enabled bool) error {
+	_, err := c.dbConfig(SQLITE_DBCONFIG_ENABLE_VIEW, enabled)
+	return err
+}
+
+// RegisterTrace registers fn to be called with the SQL text of every
+// statement as it starts running. Bound parameters are expanded into the text
+// when the library supports it. Passing nil removes the callback.
+func (c *Conn) RegisterTrace(fn func(sql string)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	c.trace = fn
+	return c.updateTrace()
+}
+
+// RegisterProfile registers fn to be called after every statement finishes
+// with its SQL text and wall-clock execution time in nanoseconds. Passing nil
+// removes the callback.
+func (c *Conn) RegisterProfile(fn func(sql string, nanos int64)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	c.profile = fn
+	return c.updateTrace()
+}
+
+// SetProfile sets fn as the profile callback, called after every statement
+// finishes with its SQL text and execution time in nanoseconds. It is the
+// same as RegisterProfile; passing nil removes the callback.
+func (c *Conn) SetProfile(fn func(sql string, nanos int64)) error {
+	return c.RegisterProfile(fn)
+}
+
+// SaveAs writes a consistent snapshot of the main database to a new file at
+// path. It is VacuumInto under another name.
+func (c *Conn) SaveAs(path string) error {
+	return c.VacuumInto(path)
+}
+
+// OnCommit registers fn to be called after a transaction started through
+// BeginTx has been durably committed. It is not called when the commit
+// fails or the transaction is rolled back. Passing nil removes the callback.
+func (c *Conn) OnCommit(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onCommit = fn
+}
+
+func (c *Conn) updateTrace() error {
+	var mask uint32
+	if c.trace != nil {
+		mask |= SQLITE_TRACE_STMT
+	}
+	if c.profile != nil {
+		mask |= SQLITE_TRACE_PROFILE
+	}
+
+	var rc int
+	if mask == 0 {
+		rc = sqlite3_trace_v2(c.db, 0, 0, 0)
+	} else {
+		initCallbacks()
+		rc = sqlite3_trace_v2(c.db, mask, traceCallback, c.handle)
 	}
 
+	if rc != SQLITE_OK {
+		return fmt.Errorf("trace failed: %w", c.lastError())
+	}
 	return nil
 }
 
+// DBConfig sets the boolean connection option op, one of the
+// SQLITE_DBCONFIG_* constants, to val: 1 enables it, 0 disables it and a
+// negative val leaves it unchanged. It returns the option's resulting value.
+// sqlite3_db_config is variadic, which purego cannot call on darwin/arm64, so
+// there DBConfig and the toggles built on it always fail.
+func (c *Conn) DBConfig(op int, val int) (int, error) {
+	if !dbConfigSupported {
+		return 0, fmt.Errorf("db config failed: not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return 0, driver.ErrBadConn
+	}
+
+	var out int32
+	rc := sqlite3_db_config(c.db, op, val, &out)
+	if rc != SQLITE_OK {
+		return 0, fmt.Errorf("db config failed: %w", c.lastError())
+	}
+
+	return int(out), nil
+}
+
+func (c *Conn) dbConfig(op int, enabled bool) (bool, error) {
+	val := 0
+	if enabled {
+		val = 1
+	}
+
+	out, err := c.DBConfig(op, val)
+	return out != 0, err
+}
+
 func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
 	return checkNamedValue(nv)
 }
@@ -230,17 +658,14 @@ func (c *Conn) execDirect(query string) (driver.Result, error) {
 		return nil, driver.ErrBadConn
 	}
 
-	queryPtr, pinner := cString(query)
-	defer unpin(pinner)
-
-	rc := sqlite3_exec(c.db, queryPtr, 0, 0, 0)
+	rc := c.exec(query)
 	if rc != SQLITE_OK {
-		return nil, fmt.Errorf("exec failed: %s", getErrorMessage(c.db))
+		return nil, fmt.Errorf("exec failed: %w", c.lastError())
 	}
 
 	return &Result{
 		lastInsertID: sqlite3_last_insert_rowid(c.db),
-		rowsAffected: int64(sqlite3_changes(c.db)),
+		rowsAffected: changes(c.db),
 	}, nil
 }
 
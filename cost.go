@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// QueryCost is a rough, static estimate of how expensive a query is.
+type QueryCost struct {
+	Opcodes   int      // Bytecode instructions in the EXPLAIN program
+	Plan      []string // EXPLAIN QUERY PLAN detail lines, in order
+	FullScans []string // Tables the plan reads in full without an index
+}
+
+// EstimateCost compiles query without running it and reports the size of its
+// bytecode program together with its query plan. The opcode count is only a
+// proxy for cost, since loops run their instructions once per row; the
+// FullScans list is usually the more useful warning. args are bound as they
+// would be for the query, which can matter for plans depending on them.
+func (c *Conn) EstimateCost(query string, args ...any) (QueryCost, error) {
+	var cost QueryCost
+
+	err := c.explain("EXPLAIN "+query, args, func(uintptr) {
+		cost.Opcodes++
+	})
+	if err != nil {
+		return QueryCost{}, err
+	}
+
+	err = c.explain("EXPLAIN QUERY PLAN "+query, args, func(stmt uintptr) {
+		detail := goString(sqlite3_column_text(stmt, 3))
+		cost.Plan = append(cost.Plan, detail)
+		if table, ok := fullScanTable(detail); ok {
+			cost.FullScans = append(cost.FullScans, table)
+		}
+	})
+	if err != nil {
+		return QueryCost{}, err
+	}
+
+	return cost, nil
+}
+
+// explain runs an EXPLAIN statement and calls fn for every row it returns.
+func (c *Conn) explain(query string, args []any, fn func(stmt uintptr)) error {
+	stmt, _, err := c.prepare(query)
+	if err != nil {
+		return err
+	}
+	if stmt == nil {
+		return errors.New("estimate cost needs a statement")
+	}
+	defer stmt.Close()
+
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := stmt.bind(context.Background(), named); err != nil {
+		return err
+	}
+
+	for rc := stmt.step(context.Background()); rc != SQLITE_DONE; rc = sqlite3_step(stmt.stmt) {
+		if rc != SQLITE_ROW {
+			return fmt.Errorf("explain failed: %w", c.lastError())
+		}
+		fn(stmt.stmt)
+	}
+	return nil
+}
+
+// fullScanTable reports the table named by a query plan line such as
+// "SCAN t" or "SCAN TABLE t" (before SQLite 3.36) that reads it without an
+// index.
+func fullScanTable(detail string) (string, bool) {
+	rest, ok := strings.CutPrefix(detail, "SCAN ")
+	if !ok || strings.Contains(rest, " USING ") || strings.HasPrefix(rest, "CONSTANT ROW") {
+		return "", false
+	}
+
+	rest = strings.TrimPrefix(rest, "TABLE ")
+	table, _, _ := strings.Cut(rest, " ")
+	return table, true
+}
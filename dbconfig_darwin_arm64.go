@@ -0,0 +1,6 @@
+package sqlite
+
+// dbConfigSupported is false because Apple's arm64 ABI passes variadic
+// arguments on the stack, while purego passes them in registers like fixed
+// ones, so sqlite3_db_config would read garbage.
+const dbConfigSupported = false
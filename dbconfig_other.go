@@ -0,0 +1,8 @@
+//go:build !darwin || !arm64
+
+package sqlite
+
+// dbConfigSupported reports whether sqlite3_db_config, which is variadic, can
+// be called through purego. Here variadic arguments are passed like fixed
+// ones, so binding it with a fixed signature is sound.
+const dbConfigSupported = true
@@ -6,10 +6,15 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 func init() {
@@ -28,7 +33,7 @@ func (d *Driver) Open(dsn string) (driver.Conn, error) {
 		return nil, err
 	}
 
-	conn, err := openDB(cfg)
+	conn, err := openWithTimeout(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -44,14 +49,106 @@ func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
 
 	return &connector{
 		driver: d,
-		dsn:    dsn,
 		cfg:    cfg,
 	}, nil
 }
 
+// Config configures a connector created with NewConnector. Fields left at
+// their zero value keep the setting from the DSN.
+type Config struct {
+	// DSN is parsed exactly like the string passed to sql.Open.
+	DSN string
+	// BusyTimeout is how long a connection waits for a locked database
+	// before returning SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// Collations are registered on every connection, keyed by name. See
+	// Conn.RegisterCollation.
+	Collations map[string]func(a, b string) int
+	// Pragmas are assignments such as "synchronous=OFF" run in order on
+	// every connection.
+	Pragmas []string
+	// PreparedStatements are compiled into the statement cache of every new
+	// connection, after Pragmas, so their first use skips the prepare. They
+	// must compile against the schema present when the connection opens and
+	// need the statement cache, which holds at most _stmt_cache_size of them.
+	PreparedStatements []string
+	// OpenTimeout bounds how long opening a connection keeps retrying while
+	// the database is locked or cannot be opened. When set, opening also
+	// reads the schema, so a database locked by another process fails at
+	// open rather than on first use.
+	OpenTimeout time.Duration
+}
+
+// Option modifies a Config.
+type Option func(*Config)
+
+// WithBusyTimeout sets Config.BusyTimeout.
+func WithBusyTimeout(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.BusyTimeout = d
+	}
+}
+
+// WithCollation adds a collation to Config.Collations.
+func WithCollation(name string, cmp func(a, b string) int) Option {
+	return func(cfg *Config) {
+		if cfg.Collations == nil {
+			cfg.Collations = make(map[string]func(a, b string) int)
+		}
+		cfg.Collations[name] = cmp
+	}
+}
+
+// WithPragma appends a pragma assignment such as "cache_size=-8000" to
+// Config.Pragmas.
+func WithPragma(pragma string) Option {
+	return func(cfg *Config) {
+		cfg.Pragmas = append(cfg.Pragmas, pragma)
+	}
+}
+
+// WithPreparedStatements appends to Config.PreparedStatements.
+func WithPreparedStatements(sqls ...string) Option {
+	return func(cfg *Config) {
+		cfg.PreparedStatements = append(cfg.PreparedStatements, sqls...)
+	}
+}
+
+// WithOpenTimeout sets Config.OpenTimeout.
+func WithOpenTimeout(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.OpenTimeout = d
+	}
+}
+
+// NewConnector returns a connector for use with sql.OpenDB. opts are applied
+// to cfg first.
+func NewConnector(cfg Config, opts ...Option) (driver.Connector, error) {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c, err := parseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.BusyTimeout > 0 {
+		c.busyTimeout = durationMillis(cfg.BusyTimeout)
+	}
+	c.collations = cfg.Collations
+	c.pragmas = cfg.Pragmas
+	c.preparedSQL = cfg.PreparedStatements
+	c.openTimeout = cfg.OpenTimeout
+
+	return &connector{
+		driver: &Driver{},
+		cfg:    c,
+	}, nil
+}
+
 type connector struct {
 	driver *Driver
-	dsn    string
 	cfg    *config
 }
 
@@ -62,7 +159,11 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 	default:
 	}
 
-	return c.driver.Open(c.dsn)
+	if err := loadSQLite3(); err != nil {
+		return nil, err
+	}
+
+	return openWithTimeout(c.cfg)
 }
 
 func (c *connector) Driver() driver.Driver {
@@ -76,17 +177,46 @@ var (
 )
 
 type config struct {
-	path        string
-	flags       int
-	busyTimeout int
-	cache       bool
-	mutex       string
+	path          string
+	flags         int
+	busyTimeout   int
+	cache         bool
+	mutex         string
+	normalizeUTC  bool
+	stmtCacheSize int
+	stmtMapSize   int           // Preallocated LockedMap capacity, zero for ThreadSafeMap
+	timeUnit      time.Duration // Unit of integer timestamps, zero to infer from magnitude
+	collations    map[string]func(a, b string) int
+	pragmas       []string
+	dsnPragmas    []string
+	attachments   []attachment
+	preparedSQL   []string
+	columnCase    string // "upper" or "lower" to fold Rows.Columns, empty to preserve
+	uint64Text    bool   // Bind uint64 values above math.MaxInt64 as TEXT
+	zeroCopy      bool   // Return BLOB and JSON values aliasing SQLite's buffers
+	strictFloat   bool   // Reject NaN and infinite floats instead of binding them
+	immutable     bool   // Open with immutable=1, skipping locks and WAL files
+	queryTimeout  time.Duration
+	autoWAL       bool // Enable WAL unless the database is on a network filesystem
+	busyRetries   int
+	busyBackoff   time.Duration
+	timeFormat    string
+	openTimeout   time.Duration
+	timePrecision time.Duration
+}
+
+// attachment is a database attached to every connection by an _attach DSN
+// parameter.
+type attachment struct {
+	schema string
+	path   string
 }
 
 func parseDSN(dsn string) (*config, error) {
 	cfg := &config{
-		path:  dsn,
-		flags: SQLITE_OPEN_READWRITE | SQLITE_OPEN_CREATE,
+		path:          dsn,
+		flags:         SQLITE_OPEN_READWRITE | SQLITE_OPEN_CREATE,
+		stmtCacheSize: 100,
 	}
 
 	if dsn == "" {
@@ -99,7 +229,11 @@ func parseDSN(dsn string) (*config, error) {
 			return nil, fmt.Errorf("invalid DSN: %w", err)
 		}
 
+		// Relative paths such as file:test.db parse as opaque URLs.
 		cfg.path = u.Path
+		if cfg.path == "" {
+			cfg.path = u.Opaque
+		}
 
 		q := u.Query()
 
@@ -118,6 +252,13 @@ func parseDSN(dsn string) (*config, error) {
 			}
 		}
 
+		if immutable := q.Get("immutable"); immutable != "" {
+			cfg.immutable, err = parseBool("immutable", immutable)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		if cache := q.Get("cache"); cache != "" {
 			switch cache {
 			case "shared":
@@ -144,6 +285,178 @@ func parseDSN(dsn string) (*config, error) {
 				cfg.busyTimeout = timeout
 			}
 		}
+
+		if nu := q.Get("_normalize_utc"); nu != "" {
+			normalize, err := parseBool("_normalize_utc", nu)
+			if err != nil {
+				return nil, err
+			}
+			cfg.normalizeUTC = normalize
+		}
+
+		if ut := q.Get("_uint64_text"); ut != "" {
+			uint64Text, err := parseBool("_uint64_text", ut)
+			if err != nil {
+				return nil, err
+			}
+			cfg.uint64Text = uint64Text
+		}
+
+		if zc := q.Get("_zero_copy"); zc != "" {
+			zeroCopy, err := parseBool("_zero_copy", zc)
+			if err != nil {
+				return nil, err
+			}
+			cfg.zeroCopy = zeroCopy
+		}
+
+		if sf := q.Get("_strict_float"); sf != "" {
+			strictFloat, err := parseBool("_strict_float", sf)
+			if err != nil {
+				return nil, err
+			}
+			cfg.strictFloat = strictFloat
+		}
+
+		if cs := q.Get("_stmt_cache_size"); cs != "" {
+			size, err := strconv.Atoi(cs)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid _stmt_cache_size: %s", cs)
+			}
+			cfg.stmtCacheSize = size
+		}
+
+		if ms := q.Get("_stmt_map_size"); ms != "" {
+			size, err := strconv.Atoi(ms)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid _stmt_map_size: %s", ms)
+			}
+			cfg.stmtMapSize = size
+		}
+
+		if cc := q.Get("_column_case"); cc != "" {
+			switch cc {
+			case "upper", "lower":
+				cfg.columnCase = cc
+			case "preserve":
+			default:
+				return nil, fmt.Errorf("invalid _column_case: %s", cc)
+			}
+		}
+
+		if tf := q.Get("_time_format"); tf != "" {
+			switch tf {
+			case "sqlite":
+				cfg.timeFormat = sqliteTimeFormat
+			case "rfc3339":
+			default:
+				return nil, fmt.Errorf("invalid _time_format: %s", tf)
+			}
+		}
+
+		if tp := q.Get("_time_precision"); tp != "" {
+			switch tp {
+			case "second":
+				cfg.timePrecision = time.Second
+			case "milli":
+				cfg.timePrecision = time.Millisecond
+			case "micro":
+				cfg.timePrecision = time.Microsecond
+			case "nano":
+			default:
+				return nil, fmt.Errorf("invalid _time_precision: %s", tp)
+			}
+		}
+
+		if aw := q.Get("_auto_wal"); aw != "" {
+			autoWAL, err := parseBool("_auto_wal", aw)
+			if err != nil {
+				return nil, err
+			}
+			cfg.autoWAL = autoWAL
+		}
+
+		if qt := q.Get("_query_timeout"); qt != "" {
+			timeout, err := time.ParseDuration(qt)
+			if err != nil || timeout < 0 {
+				return nil, fmt.Errorf("invalid _query_timeout: %s", qt)
+			}
+			cfg.queryTimeout = timeout
+		}
+
+		if rb := q.Get("_retry_busy"); rb != "" {
+			attempts, err := strconv.Atoi(rb)
+			if err != nil || attempts < 0 {
+				return nil, fmt.Errorf("invalid _retry_busy: %s", rb)
+			}
+			cfg.busyRetries = attempts
+			cfg.busyBackoff = defaultBusyBackoff
+		}
+
+		if rb := q.Get("_retry_backoff"); rb != "" {
+			backoff, err := time.ParseDuration(rb)
+			if err != nil || backoff < 0 {
+				return nil, fmt.Errorf("invalid _retry_backoff: %s", rb)
+			}
+			cfg.busyBackoff = backoff
+		}
+
+		if cs := q.Get("_cache_size"); cs != "" {
+			size, err := strconv.ParseInt(cs, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid _cache_size: %s", cs)
+			}
+			cfg.dsnPragmas = append(cfg.dsnPragmas, fmt.Sprintf("cache_size = %d", size))
+		}
+
+		if ms := q.Get("_mmap_size"); ms != "" {
+			size, err := strconv.ParseInt(ms, 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid _mmap_size: %s", ms)
+			}
+			cfg.dsnPragmas = append(cfg.dsnPragmas, fmt.Sprintf("mmap_size = %d", size))
+		}
+
+		if sd := q.Get("_secure_delete"); sd != "" {
+			switch sd {
+			case "on", "off", "fast":
+				cfg.dsnPragmas = append(cfg.dsnPragmas, "secure_delete = "+sd)
+			default:
+				return nil, fmt.Errorf("invalid _secure_delete: %s", sd)
+			}
+		}
+
+		if ts := q.Get("_temp_store"); ts != "" {
+			switch ts {
+			case "default", "file", "memory":
+				cfg.dsnPragmas = append(cfg.dsnPragmas, "temp_store = "+ts)
+			default:
+				return nil, fmt.Errorf("invalid _temp_store: %s", ts)
+			}
+		}
+
+		for _, a := range q["_attach"] {
+			schema, path, ok := strings.Cut(a, "=")
+			if !ok || schema == "" || path == "" {
+				return nil, fmt.Errorf("invalid _attach: %s", a)
+			}
+			cfg.attachments = append(cfg.attachments, attachment{schema: schema, path: path})
+		}
+
+		if tu := q.Get("_time_unit"); tu != "" {
+			switch tu {
+			case "s":
+				cfg.timeUnit = time.Second
+			case "ms":
+				cfg.timeUnit = time.Millisecond
+			case "us":
+				cfg.timeUnit = time.Microsecond
+			case "ns":
+				cfg.timeUnit = time.Nanosecond
+			default:
+				return nil, fmt.Errorf("invalid _time_unit: %s", tu)
+			}
+		}
 	}
 
 	if dsn == ":memory:" {
@@ -156,31 +469,208 @@ func parseDSN(dsn string) (*config, error) {
 	return cfg, nil
 }
 
+// durationMillis converts d to whole milliseconds for SQLite's C int
+// arguments, rounding positive sub-millisecond values up and clamping to the
+// int32 range.
+func durationMillis(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+
+	ms := d.Milliseconds()
+	if ms == 0 {
+		return 1
+	}
+	if ms > math.MaxInt32 {
+		return math.MaxInt32
+	}
+
+	return int(ms)
+}
+
+func parseBool(key, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "on", "yes":
+		return true, nil
+	case "0", "false", "off", "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid %s: %s", key, value)
+	}
+}
+
+// openWithTimeout opens a connection, retrying for up to cfg.openTimeout
+// while the database is busy, locked or cannot be opened. The busy timeout
+// is capped by the time left so that a single attempt cannot overrun it.
+func openWithTimeout(cfg *config) (*Conn, error) {
+	if cfg.openTimeout <= 0 {
+		return openDB(cfg)
+	}
+
+	deadline := time.Now().Add(cfg.openTimeout)
+	backoff := 10 * time.Millisecond
+	for {
+		attempt := *cfg
+		attempt.busyTimeout = min(cfg.busyTimeout, durationMillis(time.Until(deadline)))
+
+		conn, err := openDB(&attempt)
+		if err == nil {
+			_, err = conn.execDirect("SELECT 1 FROM sqlite_master LIMIT 1")
+			if err == nil {
+				sqlite3_busy_timeout(conn.db, cfg.busyTimeout)
+				return conn, nil
+			}
+			conn.Close()
+		}
+
+		var sqliteErr *Error
+		if !errors.As(err, &sqliteErr) ||
+			sqliteErr.Code != SQLITE_BUSY && sqliteErr.Code != SQLITE_LOCKED && sqliteErr.Code != SQLITE_CANTOPEN {
+			return nil, err
+		}
+
+		wait := min(backoff, time.Until(deadline))
+		if wait <= 0 {
+			return nil, fmt.Errorf("open timed out after %v: %w", cfg.openTimeout, err)
+		}
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// walPending reports whether the database at path has a non-empty -wal file,
+// which may hold committed transactions not yet checkpointed into it.
+func walPending(path string) bool {
+	info, err := os.Stat(path + "-wal")
+	return err == nil && info.Size() > 0
+}
+
 func openDB(cfg *config) (*Conn, error) {
 	var db uintptr
 
-	pathPtr, pinner := cString(cfg.path)
+	path := cfg.path
+	if cfg.immutable {
+		// A relative path would be read as the URI's authority, so make it
+		// absolute first.
+		abs, err := filepath.Abs(cfg.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		abs = filepath.ToSlash(abs)
+		if !strings.HasPrefix(abs, "/") {
+			abs = "/" + abs // Windows drive letter paths
+		}
+		u := url.URL{Scheme: "file", Path: abs, RawQuery: "immutable=1"}
+		path = u.String()
+	}
+
+	pathPtr, pinner := cString(path)
 	defer unpin(pinner)
 
-	rc := sqlite3_open_v2(pathPtr, &db, cfg.flags, 0)
+	rc := sqlite3_open_v2(pathPtr, &db, mutexFlags(cfg.flags, sqlite3_threadsafe()), 0)
 	if rc != SQLITE_OK {
 		if db != 0 {
-			errMsg := getErrorMessage(db)
+			err := newError(db)
 			sqlite3_close(db)
-			return nil, fmt.Errorf("failed to open database: %s", errMsg)
+			return nil, fmt.Errorf("failed to open database: %w", err)
 		}
 		return nil, fmt.Errorf("failed to open database: %s", errorString(rc))
 	}
 
 	conn := &Conn{
-		db:    db,
-		stmts: NewThreadSafeMap[uintptr, *Stmt](),
-		mu:    &sync.Mutex{},
+		db:          db,
+		cfg:         cfg,
+		mu:          &sync.Mutex{},
+		busyRetries: cfg.busyRetries,
+		busyBackoff: cfg.busyBackoff,
+		active:      NewThreadSafeMap[int, ActiveStmt](),
+	}
+	if cfg.stmtMapSize > 0 {
+		conn.stmts = NewLockedMap[uintptr, *Stmt](cfg.stmtMapSize)
+	} else {
+		conn.stmts = NewThreadSafeMap[uintptr, *Stmt]()
+	}
+	registerConn(conn)
+
+	if cfg.stmtCacheSize > 0 {
+		conn.cache = newStmtCache(cfg.stmtCacheSize)
 	}
 
 	if cfg.busyTimeout > 0 {
 		sqlite3_busy_timeout(db, cfg.busyTimeout)
 	}
 
+	// Some builds enable extension loading by default; require EnableLoadExtension.
+	if sqlite3_enable_load_extension != nil {
+		if rc := sqlite3_enable_load_extension(db, 0); rc != SQLITE_OK {
+			err := conn.lastError()
+			conn.Close()
+			return nil, fmt.Errorf("failed to disable extension loading: %w", err)
+		}
+	}
+
+	for name, cmp := range cfg.collations {
+		if err := conn.registerCollation(name, cmp); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.queryTimeout > 0 {
+		conn.updateProgress()
+	}
+
+	// A read-only reader of a WAL database needs the -wal and -shm files, and
+	// cannot create them in a read-only directory. Such a database can still
+	// be read as an immutable file, unless a -wal file holds transactions
+	// that reading the main file alone would silently miss.
+	if cfg.flags&SQLITE_OPEN_READONLY != 0 && !cfg.immutable && !walPending(cfg.path) {
+		if _, err := conn.execDirect("SELECT 1 FROM sqlite_master LIMIT 1"); err != nil {
+			var sqliteErr *Error
+			if errors.As(err, &sqliteErr) && (sqliteErr.Code == SQLITE_CANTOPEN || sqliteErr.Code == SQLITE_READONLY) {
+				conn.Close()
+				immutable := *cfg
+				immutable.immutable = true
+				return openDB(&immutable)
+			}
+		}
+	}
+
+	if cfg.autoWAL && cfg.flags&SQLITE_OPEN_MEMORY == 0 && cfg.flags&SQLITE_OPEN_READONLY == 0 {
+		if err := conn.autoJournalMode(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	for _, a := range cfg.attachments {
+		args := []driver.NamedValue{{Ordinal: 1, Value: a.path}, {Ordinal: 2, Value: a.schema}}
+		if _, err := conn.ExecContext(context.Background(), "ATTACH DATABASE ? AS ?", args); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("attach %s: %w", a.schema, err)
+		}
+	}
+
+	// DSN pragmas run first so that Config.Pragmas can override them.
+	for _, pragma := range slices.Concat(cfg.dsnPragmas, cfg.pragmas) {
+		if _, err := conn.execDirect("PRAGMA " + pragma); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("pragma %s: %w", pragma, err)
+		}
+	}
+
+	if conn.cache != nil {
+		for _, query := range cfg.preparedSQL {
+			stmt, _, err := conn.prepare(query)
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("prepare %q: %w", query, err)
+			}
+			if stmt != nil {
+				stmt.Close()
+			}
+		}
+	}
+
 	return conn, nil
 }
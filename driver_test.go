@@ -1,11 +1,21 @@
 package sqlite
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -436,6 +446,39 @@ func TestValuerInterface(t *testing.T) {
 	if result != "custom:test" {
 		t.Errorf("Expected 'custom:test', got %s", result)
 	}
+
+	// A Valuer must resolve to a driver.Value; one returning itself would
+	// otherwise recurse forever.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		stmt, _, err := driverConn.(*Conn).prepare("SELECT ?")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		if err := stmt.bindValue(1, selfValuer{}); err == nil {
+			t.Error("Expected error binding a Valuer that returns itself")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	if AffinityOf(selfValuer{}) != 0 {
+		t.Error("Expected no affinity for a Valuer that returns itself")
+	}
+}
+
+type selfValuer struct{}
+
+func (v selfValuer) Value() (driver.Value, error) {
+	return v, nil
 }
 
 func TestFileDatabase(t *testing.T) {
@@ -491,8 +534,39 @@ func TestDSNParsing(t *testing.T) {
 		{"file:test.db?mode=memory", false},
 		{"file:test.db?cache=shared", false},
 		{"file:test.db?cache=private", false},
+		{"file:test.db?_normalize_utc=on", false},
+		{"file:test.db?_stmt_cache_size=0", false},
 		{"", true},
 		{"file:test.db?mode=invalid", true},
+		{"file:test.db?_normalize_utc=maybe", true},
+		{"file:test.db?_stmt_cache_size=-1", true},
+		{"file:test.db?_stmt_map_size=64", false},
+		{"file:test.db?_stmt_map_size=x", true},
+		{"file:test.db?_column_case=preserve", false},
+		{"file:test.db?_query_timeout=250ms", false},
+		{"file:test.db?_auto_wal=on", false},
+		{"file:test.db?_strict_float=on", false},
+		{"file:test.db?_zero_copy=on", false},
+		{"file:test.db?_zero_copy=maybe", true},
+		{"file:test.db?_cache_size=-8000", false},
+		{"file:test.db?_cache_size=2000", false},
+		{"file:test.db?_cache_size=big", true},
+		{"file:test.db?_mmap_size=268435456", false},
+		{"file:test.db?_mmap_size=-1", true},
+		{"file:test.db?_secure_delete=fast", false},
+		{"file:test.db?_secure_delete=yes", true},
+		{"file:test.db?_temp_store=memory", false},
+		{"file:test.db?_temp_store=disk", true},
+		{"file:test.db?_attach=aux=other.db&_attach=logs=logs.db", false},
+		{"file:test.db?_attach=other.db", true},
+		{"file:test.db?_attach==other.db", true},
+		{"file:test.db?_strict_float=maybe", true},
+		{"file:test.db?_query_timeout=250", true},
+		{"file:test.db?mode=ro&immutable=1", false},
+		{"file:test.db?immutable=maybe", true},
+		{"file:test.db?_column_case=title", true},
+		{"file:test.db?_time_unit=ms", false},
+		{"file:test.db?_time_unit=minutes", true},
 	}
 
 	for _, tt := range tests {
@@ -607,6 +681,38 @@ func TestDeclaredTypeHandling(t *testing.T) {
 		}
 	})
 
+	t.Run("Boolean nonzero values", func(t *testing.T) {
+		tests := []struct {
+			value    any
+			expected bool
+		}{
+			{0, false},
+			{1, true},
+			{2, true},
+			{-1, true},
+			{0.5, true},
+			{0.0, false},
+		}
+
+		for _, tt := range tests {
+			var id int64
+			err := db.QueryRow("INSERT INTO type_test (bool_int) VALUES (?) RETURNING id", tt.value).Scan(&id)
+			if err != nil {
+				t.Fatalf("Failed to insert %v: %v", tt.value, err)
+			}
+
+			var result bool
+			err = db.QueryRow("SELECT bool_int FROM type_test WHERE id = ?", id).Scan(&result)
+			if err != nil {
+				t.Fatalf("Failed to scan %v: %v", tt.value, err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %v for %v, got %v", tt.expected, tt.value, result)
+			}
+		}
+	})
+
 	t.Run("DateTime as TEXT", func(t *testing.T) {
 		testTime := time.Date(2024, 3, 15, 14, 30, 45, 123456789, time.UTC)
 
@@ -833,3 +939,6046 @@ func TestDeclaredTypeHandling(t *testing.T) {
 		}
 	})
 }
+
+func TestExtendedErrorCodes(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO users (email) VALUES (?)", "alice@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO users (email) VALUES (?)", "alice@example.com")
+	if err == nil {
+		t.Fatal("Expected unique constraint violation")
+	}
+
+	var sqliteErr *Error
+	if !errors.As(err, &sqliteErr) {
+		t.Fatalf("Expected *Error, got %T: %v", err, err)
+	}
+
+	if sqliteErr.Code != SQLITE_CONSTRAINT {
+		t.Errorf("Expected code SQLITE_CONSTRAINT, got %d", sqliteErr.Code)
+	}
+	if sqliteErr.ExtendedCode != SQLITE_CONSTRAINT_UNIQUE {
+		t.Errorf("Expected extended code %s, got %s",
+			extendedErrorString(SQLITE_CONSTRAINT_UNIQUE), extendedErrorString(sqliteErr.ExtendedCode))
+	}
+	if !strings.Contains(err.Error(), "SQLITE_CONSTRAINT_UNIQUE") {
+		t.Errorf("Expected error message to name the extended code, got %q", err.Error())
+	}
+}
+
+func TestSetTriggersEnabled(t *testing.T) {
+	if !dbConfigSupported {
+		t.Skip("sqlite3_db_config cannot be called on this platform")
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE audit (item_id INTEGER);
+		CREATE TRIGGER items_audit AFTER INSERT ON items BEGIN
+			INSERT INTO audit (item_id) VALUES (new.id);
+		END;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetTriggersEnabled(false)
+	})
+	if err != nil {
+		t.Fatalf("Failed to disable triggers: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), "INSERT INTO items (name) VALUES (?)", "widget")
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	var count int
+	err = conn.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM audit").Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count audit rows: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected trigger not to fire, got %d audit rows", count)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetTriggersEnabled(true)
+	})
+	if err != nil {
+		t.Fatalf("Failed to enable triggers: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), "INSERT INTO items (name) VALUES (?)", "gadget")
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	err = conn.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM audit").Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count audit rows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected trigger to fire once, got %d audit rows", count)
+	}
+}
+
+func TestQueryFinalizesStatements(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:finalize.db?mode=memory&_stmt_cache_size=0")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO items (name) VALUES (?)", "widget")
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	preparedBefore := stmtsPrepared.Load()
+	finalizedBefore := stmtsFinalized.Load()
+
+	const queries = 5000
+	for i := 0; i < queries; i++ {
+		var name string
+		if err := db.QueryRow("SELECT name FROM items WHERE id = ?", 1).Scan(&name); err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+	}
+
+	prepared := stmtsPrepared.Load() - preparedBefore
+	finalized := stmtsFinalized.Load() - finalizedBefore
+	if prepared != queries {
+		t.Errorf("Expected %d statements prepared, got %d", queries, prepared)
+	}
+	if prepared != finalized {
+		t.Errorf("Expected every prepared statement to be finalized, %d prepared but %d finalized", prepared, finalized)
+	}
+}
+
+func TestNormalizeUTC(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "utc.db")
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_normalize_utc=on", dbPath))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE events (id INTEGER PRIMARY KEY, at TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	amsterdam := time.FixedZone("CEST", 2*60*60)
+	at := time.Date(2024, 6, 1, 14, 0, 0, 0, amsterdam)
+
+	_, err = db.Exec("INSERT INTO events (at) VALUES (?)", at)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	var stored string
+	err = db.QueryRow("SELECT at FROM events WHERE id = 1").Scan(&stored)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	if stored != "2024-06-01T12:00:00Z" {
+		t.Errorf("Expected time stored in UTC, got %s", stored)
+	}
+}
+
+func TestClearBindingsOnReuse(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE pairs (id INTEGER PRIMARY KEY, a TEXT, b TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// database/sql always binds every parameter, so leave b unbound by
+	// stepping the reused statement directly.
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		stmt, _, err := c.prepare("INSERT INTO pairs (a, b) VALUES (?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		first := []driver.NamedValue{{Ordinal: 1, Value: "first"}, {Ordinal: 2, Value: "stale"}}
+		if _, err := stmt.ExecContext(context.Background(), first); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if err := stmt.bindValue(1, "second"); err != nil {
+			return err
+		}
+		defer stmt.reset()
+		if rc := stmt.step(context.Background()); rc != SQLITE_DONE {
+			return fmt.Errorf("step failed: %w", stmt.stepError())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	var b sql.NullString
+	err = conn.QueryRowContext(context.Background(), "SELECT b FROM pairs WHERE a = ?", "second").Scan(&b)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	if b.Valid {
+		t.Errorf("Expected NULL, got stale value %q", b.String)
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var profiled []string
+	var durations []int64
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetProfile(func(sql string, nanos int64) {
+			profiled = append(profiled, sql)
+			durations = append(durations, nanos)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to set profile callback: %v", err)
+	}
+
+	query := "WITH RECURSIVE cnt(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM cnt WHERE x < 10000) SELECT SUM(x) FROM cnt"
+	var sum int64
+	if err := conn.QueryRowContext(context.Background(), query).Scan(&sum); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	if len(profiled) == 0 {
+		t.Fatal("Expected profile callback to fire")
+	}
+	if profiled[len(profiled)-1] != query {
+		t.Errorf("Expected profiled SQL %q, got %q", query, profiled[len(profiled)-1])
+	}
+	if durations[len(durations)-1] <= 0 {
+		t.Errorf("Expected positive duration, got %d", durations[len(durations)-1])
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetProfile(nil)
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove profile callback: %v", err)
+	}
+
+	calls := len(profiled)
+	if _, err := conn.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("Failed to exec: %v", err)
+	}
+	if len(profiled) != calls {
+		t.Errorf("Expected no profile callbacks after removal, got %d more", len(profiled)-calls)
+	}
+}
+
+func TestSavepoints(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE items (name TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	savepoint := func(fn func(c *Conn) error) {
+		t.Helper()
+		err := conn.Raw(func(driverConn any) error {
+			return fn(driverConn.(*Conn))
+		})
+		if err != nil {
+			t.Fatalf("Savepoint operation failed: %v", err)
+		}
+	}
+
+	insert := func(name string) {
+		t.Helper()
+		_, err := conn.ExecContext(context.Background(), "INSERT INTO items (name) VALUES (?)", name)
+		if err != nil {
+			t.Fatalf("Failed to insert %s: %v", name, err)
+		}
+	}
+
+	inner := `inner"; DROP TABLE items; --`
+
+	savepoint(func(c *Conn) error { return c.Savepoint("outer") })
+	insert("kept")
+	savepoint(func(c *Conn) error { return c.Savepoint(inner) })
+	insert("discarded")
+	savepoint(func(c *Conn) error { return c.RollbackSavepoint(inner) })
+	savepoint(func(c *Conn) error { return c.ReleaseSavepoint("outer") })
+
+	var names []string
+	rows, err := conn.QueryContext(context.Background(), "SELECT name FROM items ORDER BY rowid")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Failed to scan: %v", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Failed to iterate rows: %v", err)
+	}
+
+	if len(names) != 1 || names[0] != "kept" {
+		t.Errorf("Expected only the outer savepoint's row, got %v", names)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).ReleaseSavepoint("missing")
+	})
+	if err == nil {
+		t.Error("Expected error releasing an unknown savepoint")
+	}
+}
+
+func TestAffinityOf(t *testing.T) {
+	tests := []struct {
+		name  string
+		value driver.Value
+		want  int
+	}{
+		{"nil", nil, SQLITE_NULL},
+		{"int", 42, SQLITE_INTEGER},
+		{"int64", int64(42), SQLITE_INTEGER},
+		{"uint8", uint8(1), SQLITE_INTEGER},
+		{"bool", true, SQLITE_INTEGER},
+		{"float64", 3.14, SQLITE_REAL},
+		{"float32", float32(3.14), SQLITE_REAL},
+		{"string", "hello", SQLITE_TEXT},
+		{"time", time.Now(), SQLITE_TEXT},
+		{"bytes", []byte{0x01}, SQLITE_BLOB},
+		{"empty bytes", []byte{}, SQLITE_BLOB},
+		{"nil bytes", []byte(nil), SQLITE_NULL},
+		{"nil raw JSON", json.RawMessage(nil), SQLITE_NULL},
+		{"big int", big.NewInt(7), SQLITE_TEXT},
+		{"nil big int", (*big.Int)(nil), SQLITE_NULL},
+		{"nil big rat", (*big.Rat)(nil), SQLITE_NULL},
+		{"int pointer", func() *int { n := 1; return &n }(), SQLITE_INTEGER},
+		{"string pointer", func() *string { s := "x"; return &s }(), SQLITE_TEXT},
+		{"nil int pointer", (*int)(nil), SQLITE_NULL},
+		{"nil NullString pointer", (*sql.NullString)(nil), SQLITE_NULL},
+		{"nil time pointer", (*time.Time)(nil), SQLITE_NULL},
+		{"uint64", uint64(math.MaxInt64), SQLITE_INTEGER},
+		{"uint64 overflow", uint64(math.MaxUint64), 0},
+		{"valuer", CustomValuer{Data: "x"}, SQLITE_TEXT},
+		{"null string", sql.NullString{}, SQLITE_NULL},
+		{"unsupported", struct{}{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AffinityOf(tt.value); got != tt.want {
+				t.Errorf("AffinityOf(%v) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	// With _uint64_text, values above math.MaxInt64 bind as TEXT.
+	db, err := sql.Open("sqlite3", "file::memory:?_uint64_text=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		if got := c.AffinityOf(uint64(math.MaxUint64)); got != SQLITE_TEXT {
+			t.Errorf("Expected TEXT for uint64 overflow with _uint64_text, got %d", got)
+		}
+		if got := c.AffinityOf(uint64(1)); got != SQLITE_INTEGER {
+			t.Errorf("Expected INTEGER for small uint64, got %d", got)
+		}
+		return nil
+	})
+}
+
+func TestReturningClause(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		var id int64
+		var createdAt string
+		err := db.QueryRow("INSERT INTO users (name) VALUES (?) RETURNING id, created_at", "Alice").Scan(&id, &createdAt)
+		if err != nil {
+			t.Fatalf("Failed to insert with RETURNING: %v", err)
+		}
+
+		if id != 1 {
+			t.Errorf("Expected id 1, got %d", id)
+		}
+		if _, ok := parseTimeString(createdAt); !ok {
+			t.Errorf("Expected created_at to be a timestamp, got %q", createdAt)
+		}
+	})
+
+	t.Run("Exec", func(t *testing.T) {
+		result, err := db.Exec("INSERT INTO users (name) VALUES (?), (?) RETURNING id", "Bob", "Carol")
+		if err != nil {
+			t.Fatalf("Failed to insert with RETURNING: %v", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			t.Fatalf("Failed to get rows affected: %v", err)
+		}
+		if rowsAffected != 2 {
+			t.Errorf("Expected 2 rows affected, got %d", rowsAffected)
+		}
+
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			t.Fatalf("Failed to get last insert ID: %v", err)
+		}
+		if lastID != 3 {
+			t.Errorf("Expected last insert ID 3, got %d", lastID)
+		}
+	})
+
+	t.Run("Exec without args", func(t *testing.T) {
+		result, err := db.Exec("INSERT INTO users (name) VALUES ('Dave'), ('Erin') RETURNING id")
+		if err != nil {
+			t.Fatalf("Failed to insert with RETURNING: %v", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			t.Fatalf("Failed to get rows affected: %v", err)
+		}
+		if rowsAffected != 2 {
+			t.Errorf("Expected 2 rows affected, got %d", rowsAffected)
+		}
+
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			t.Fatalf("Failed to get last insert ID: %v", err)
+		}
+		if lastID != 5 {
+			t.Errorf("Expected last insert ID 5, got %d", lastID)
+		}
+	})
+
+	t.Run("Query without args", func(t *testing.T) {
+		rows, err := db.Query("INSERT INTO users (name) VALUES ('Frank'), ('Grace') RETURNING id")
+		if err != nil {
+			t.Fatalf("Failed to insert with RETURNING: %v", err)
+		}
+		defer rows.Close()
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("Failed to scan: %v", err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Failed to iterate rows: %v", err)
+		}
+
+		if len(ids) != 2 || ids[0] != 6 || ids[1] != 7 {
+			t.Errorf("Expected ids [6 7], got %v", ids)
+		}
+	})
+}
+
+func TestExecMultipleStatementsWithArgs(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT, balance INTEGER)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO accounts (owner, balance) VALUES (?, ?);
+		UPDATE accounts SET balance = balance + ? WHERE owner = ?;
+	`, "alice", 100, 50, "alice")
+	if err != nil {
+		t.Fatalf("Failed to execute statements: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		t.Fatalf("Failed to get rows affected: %v", err)
+	}
+	if rowsAffected != 1 {
+		t.Errorf("Expected 1 row affected by the last statement, got %d", rowsAffected)
+	}
+
+	var balance int
+	err = db.QueryRow("SELECT balance FROM accounts WHERE owner = ?", "alice").Scan(&balance)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if balance != 150 {
+		t.Errorf("Expected balance 150, got %d", balance)
+	}
+
+	_, err = db.Exec("INSERT INTO accounts (owner) VALUES (?); SELECT ?", "bob")
+	if err == nil {
+		t.Error("Expected error when arguments run out")
+	}
+
+	_, err = db.Exec("INSERT INTO accounts (owner) VALUES (?)", "carol", "extra")
+	if err == nil {
+		t.Error("Expected error for unused arguments")
+	}
+}
+
+func TestOnCommit(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE events (name TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	var commits int
+	err = conn.Raw(func(driverConn any) error {
+		driverConn.(*Conn).OnCommit(func() { commits++ })
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to register commit callback: %v", err)
+	}
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO events (name) VALUES (?)", "rolled back"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to rollback: %v", err)
+	}
+
+	if commits != 0 {
+		t.Errorf("Expected no commit callback after rollback, got %d", commits)
+	}
+
+	tx, err = conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO events (name) VALUES (?)", "committed"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if commits != 0 {
+		t.Errorf("Expected no commit callback before commit, got %d", commits)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if commits != 1 {
+		t.Errorf("Expected one commit callback, got %d", commits)
+	}
+}
+
+func TestConflictResolutionResults(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE kv (id INTEGER PRIMARY KEY, k TEXT UNIQUE, v TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		key              string
+		wantLastInsertID int64
+		wantRowsAffected int64
+	}{
+		{"insert", "INSERT INTO kv (k, v) VALUES (?, ?)", "a", 1, 1},
+		{"insert second", "INSERT INTO kv (k, v) VALUES (?, ?)", "b", 2, 1},
+		{"or replace", "INSERT OR REPLACE INTO kv (k, v) VALUES (?, ?)", "a", 3, 1},
+		{"or ignore skipped", "INSERT OR IGNORE INTO kv (k, v) VALUES (?, ?)", "b", 3, 0},
+		{"or ignore inserted", "INSERT OR IGNORE INTO kv (k, v) VALUES (?, ?)", "c", 4, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := db.Exec(tt.query, tt.key, "value")
+			if err != nil {
+				t.Fatalf("Failed to execute: %v", err)
+			}
+
+			lastID, err := result.LastInsertId()
+			if err != nil {
+				t.Fatalf("Failed to get last insert ID: %v", err)
+			}
+			if lastID != tt.wantLastInsertID {
+				t.Errorf("Expected last insert ID %d, got %d", tt.wantLastInsertID, lastID)
+			}
+
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				t.Fatalf("Failed to get rows affected: %v", err)
+			}
+			if rowsAffected != tt.wantRowsAffected {
+				t.Errorf("Expected %d rows affected, got %d", tt.wantRowsAffected, rowsAffected)
+			}
+		})
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM kv").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 rows after replace, got %d", count)
+	}
+}
+
+func TestStatementCache(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:stmtcache.db?mode=memory&_stmt_cache_size=2")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	preparedBefore := stmtsPrepared.Load()
+	for i := 0; i < 100; i++ {
+		_, err := conn.ExecContext(context.Background(), "INSERT INTO items (name) VALUES (?)", fmt.Sprintf("item%d", i))
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if prepared := stmtsPrepared.Load() - preparedBefore; prepared != 1 {
+		t.Errorf("Expected the insert to be prepared once, got %d", prepared)
+	}
+
+	queries := []string{"SELECT 1", "SELECT 2", "SELECT 3"}
+	for _, query := range queries {
+		var n int
+		if err := conn.QueryRowContext(context.Background(), query).Scan(&n); err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		if n := c.cache.len(); n != 2 {
+			t.Errorf("Expected cache to hold 2 statements, got %d", n)
+		}
+		if _, ok := c.cache.items["SELECT 1"]; ok {
+			t.Error("Expected least recently used statement to be evicted")
+		}
+		if n := c.stmts.Len(); n != 2 {
+			t.Errorf("Expected evicted statements to be finalized, %d still tracked", n)
+		}
+
+		// Releasing a statement that is already cached must not free it.
+		cached := c.cache.items["SELECT 3"].Value.(*Stmt)
+		if !c.release(cached) {
+			t.Error("Expected releasing a cached statement to keep it")
+		}
+		if cached.closed {
+			t.Error("Expected cached statement to stay open after a second release")
+		}
+		if n := c.cache.len(); n != 2 {
+			t.Errorf("Expected cache to still hold 2 statements, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to inspect connection: %v", err)
+	}
+}
+
+func BenchmarkStatementCache(b *testing.B) {
+	for _, size := range []int{0, 100} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			db, err := sql.Open("sqlite3", fmt.Sprintf("file:bench%d.db?mode=memory&_stmt_cache_size=%d", size, size))
+			if err != nil {
+				b.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			_, err = db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, qty INTEGER)`)
+			if err != nil {
+				b.Fatalf("Failed to create table: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.Exec("INSERT INTO items (name, qty) VALUES (?, ?)", "widget", i); err != nil {
+					b.Fatalf("Failed to insert: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestScanStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Failed to ping database: %v", err)
+	}
+	if sqlite3_stmt_scanstatus == nil {
+		t.Skip("SQLite built without SQLITE_ENABLE_STMT_SCANSTATUS")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE books (id INTEGER PRIMARY KEY, author_id INTEGER, title TEXT);
+		INSERT INTO authors (name) VALUES ('Ann'), ('Bob');
+		INSERT INTO books (author_id, title) VALUES (1, 'A'), (1, 'B'), (2, 'C');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+
+		stmt, err := c.PrepareContext(context.Background(),
+			"SELECT a.name, b.title FROM authors a JOIN books b ON b.author_id = a.id")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		rows, err := stmt.(*Stmt).QueryContext(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		dest := make([]driver.Value, 2)
+		for rows.Next(dest) == nil {
+		}
+
+		loops := stmt.(*Stmt).ScanStatus()
+		rows.Close()
+
+		if len(loops) != 2 {
+			t.Fatalf("Expected 2 loops for a two-table join, got %d", len(loops))
+		}
+		for i, loop := range loops {
+			if loop.Loops == 0 {
+				t.Errorf("Loop %d: expected to run at least once", i)
+			}
+			if loop.Name == "" || loop.Explain == "" {
+				t.Errorf("Loop %d: expected name and explain text, got %+v", i, loop)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to inspect scan status: %v", err)
+	}
+}
+
+func TestSaveAs(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("PRAGMA journal_mode=WAL; CREATE TABLE pairs (id INTEGER PRIMARY KEY, n INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// Every transaction inserts two rows, so a consistent copy holds an even count.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			tx, err := db.Begin()
+			if err != nil {
+				continue
+			}
+			tx.Exec("INSERT INTO pairs (n) VALUES (?)", i)
+			tx.Exec("INSERT INTO pairs (n) VALUES (?)", i)
+			tx.Commit()
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	target := filepath.Join(dir, "copy.db")
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SaveAs(target)
+	})
+	conn.Close()
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	cp, err := sql.Open("sqlite3", target)
+	if err != nil {
+		t.Fatalf("Failed to open copy: %v", err)
+	}
+	defer cp.Close()
+
+	var check string
+	if err := cp.QueryRow("PRAGMA integrity_check").Scan(&check); err != nil {
+		t.Fatalf("Failed to check copy: %v", err)
+	}
+	if check != "ok" {
+		t.Errorf("Expected integrity check ok, got %s", check)
+	}
+
+	var count int
+	if err := cp.QueryRow("SELECT COUNT(*) FROM pairs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count%2 != 0 {
+		t.Errorf("Expected an even row count in a consistent copy, got %d", count)
+	}
+
+	var original int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pairs").Scan(&original); err != nil {
+		t.Fatalf("Failed to count original rows: %v", err)
+	}
+	if original < count {
+		t.Errorf("Expected original to keep at least %d rows, got %d", count, original)
+	}
+
+	err = func() error {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return conn.Raw(func(driverConn any) error {
+			return driverConn.(*Conn).SaveAs(target)
+		})
+	}()
+	if err == nil {
+		t.Error("Expected error saving over an existing file")
+	}
+}
+
+func TestLoadExtension(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if sqlite3_load_extension == nil {
+		t.Skip("SQLite built without extension loading")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing_extension")
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+
+		err := c.LoadExtension(missing, "")
+		if err == nil {
+			t.Error("Expected error loading extension before enabling it")
+		} else if !strings.Contains(err.Error(), "not authorized") {
+			t.Errorf("Expected not authorized error, got %v", err)
+		}
+
+		if err := c.EnableLoadExtension(true); err != nil {
+			return err
+		}
+
+		err = c.LoadExtension(missing, "sqlite3_missing_init")
+		var sqliteErr *Error
+		if !errors.As(err, &sqliteErr) {
+			t.Fatalf("Expected *Error, got %v", err)
+		}
+		if !strings.Contains(sqliteErr.Message, "missing_extension") {
+			t.Errorf("Expected message naming the extension, got %q", sqliteErr.Message)
+		}
+
+		return c.EnableLoadExtension(false)
+	})
+	if err != nil {
+		t.Fatalf("Failed to toggle extension loading: %v", err)
+	}
+}
+
+func TestPragmaHelpers(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		dsn      string
+		mode     string
+		expected string
+	}{
+		{"wal on file", "file:" + filepath.Join(dir, "wal.db"), "wal", "WAL"},
+		{"truncate on file", "file:" + filepath.Join(dir, "truncate.db"), "TRUNCATE", "TRUNCATE"},
+		{"wal refused in memory", ":memory:", "WAL", "MEMORY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", tt.dsn)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+
+			conn, err := db.Conn(context.Background())
+			if err != nil {
+				t.Fatalf("Failed to get connection: %v", err)
+			}
+			defer conn.Close()
+
+			err = conn.Raw(func(driverConn any) error {
+				c := driverConn.(*Conn)
+
+				mode, err := c.SetJournalMode(tt.mode)
+				if err != nil {
+					return err
+				}
+				if mode != tt.expected {
+					t.Errorf("Expected journal mode %s, got %s", tt.expected, mode)
+				}
+
+				if err := c.SetForeignKeys(true); err != nil {
+					return err
+				}
+				if err := c.SetSynchronous("normal"); err != nil {
+					return err
+				}
+				return c.SetBusyTimeout(2 * time.Second)
+			})
+			if err != nil {
+				t.Fatalf("Failed to apply pragmas: %v", err)
+			}
+
+			var fk, synchronous, timeout int
+			if err := conn.QueryRowContext(context.Background(), "PRAGMA foreign_keys").Scan(&fk); err != nil {
+				t.Fatalf("Failed to read foreign_keys: %v", err)
+			}
+			if err := conn.QueryRowContext(context.Background(), "PRAGMA synchronous").Scan(&synchronous); err != nil {
+				t.Fatalf("Failed to read synchronous: %v", err)
+			}
+			if err := conn.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+				t.Fatalf("Failed to read busy_timeout: %v", err)
+			}
+			if fk != 1 || synchronous != 1 || timeout != 2000 {
+				t.Errorf("Expected foreign_keys=1 synchronous=1 busy_timeout=2000, got %d %d %d", fk, synchronous, timeout)
+			}
+		})
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		if _, err := c.SetJournalMode("wal; DROP TABLE x"); err == nil {
+			t.Error("Expected error for invalid journal mode")
+		}
+		if err := c.SetSynchronous("fastest"); err == nil {
+			t.Error("Expected error for invalid synchronous level")
+		}
+		return nil
+	})
+}
+
+// cancelingValuer cancels its context when the driver asks for its value,
+// simulating a cancellation that arrives while arguments are being bound.
+type cancelingValuer struct {
+	cancel context.CancelFunc
+	value  []byte
+}
+
+func (v cancelingValuer) Value() (driver.Value, error) {
+	v.cancel()
+	return v.value, nil
+}
+
+func TestBindCancellation(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:bindcancel.db?mode=memory&_stmt_cache_size=0")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE blobs (a BLOB, b BLOB, c BLOB, d BLOB)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	blob := make([]byte, 16<<20)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prepared := stmtsPrepared.Load()
+	finalized := stmtsFinalized.Load()
+
+	start := time.Now()
+	_, err = db.ExecContext(ctx, "INSERT INTO blobs VALUES (?, ?, ?, ?)",
+		blob, cancelingValuer{cancel: cancel, value: blob}, blob, blob)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected prompt return after cancellation, took %v", elapsed)
+	}
+
+	if got := stmtsPrepared.Load() - prepared; got != stmtsFinalized.Load()-finalized {
+		t.Errorf("Expected every prepared statement to be finalized, %d prepared and %d finalized",
+			got, stmtsFinalized.Load()-finalized)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM blobs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no rows after cancellation, got %d", count)
+	}
+
+	if _, err := db.Exec("INSERT INTO blobs VALUES (?, ?, ?, ?)", blob, blob, blob, blob); err != nil {
+		t.Fatalf("Failed to insert after cancellation: %v", err)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.db")
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		PRAGMA journal_mode = WAL;
+		PRAGMA wal_autocheckpoint = 0;
+		CREATE TABLE data (payload BLOB);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err := conn.ExecContext(context.Background(), "INSERT INTO data VALUES (randomblob(4096))"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	before, err := os.Stat(path + "-wal")
+	if err != nil {
+		t.Fatalf("Failed to stat wal file: %v", err)
+	}
+	if before.Size() == 0 {
+		t.Fatal("Expected wal file to contain frames")
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		busy, logFrames, checkpointed, err := driverConn.(*Conn).Checkpoint(CheckpointTruncate)
+		if err != nil {
+			return err
+		}
+		if busy != 0 {
+			t.Errorf("Expected checkpoint not to be busy, got %d", busy)
+		}
+		if logFrames != checkpointed {
+			t.Errorf("Expected all %d frames checkpointed, got %d", logFrames, checkpointed)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to checkpoint: %v", err)
+	}
+
+	after, err := os.Stat(path + "-wal")
+	if err != nil {
+		t.Fatalf("Failed to stat wal file: %v", err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("Expected wal file to shrink from %d bytes, got %d", before.Size(), after.Size())
+	}
+}
+
+func TestConnectorBusyTimeout(t *testing.T) {
+	connector, err := NewConnector(Config{
+		DSN:         ":memory:",
+		BusyTimeout: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("Failed to read busy_timeout: %v", err)
+	}
+	if timeout != 2000 {
+		t.Errorf("Expected busy_timeout 2000, got %d", timeout)
+	}
+
+	tests := []struct {
+		duration time.Duration
+		expected int
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Microsecond, 1},
+		{1500 * time.Millisecond, 1500},
+		{time.Duration(math.MaxInt64), math.MaxInt32},
+	}
+
+	for _, tt := range tests {
+		if got := durationMillis(tt.duration); got != tt.expected {
+			t.Errorf("durationMillis(%v) = %d, expected %d", tt.duration, got, tt.expected)
+		}
+	}
+}
+
+func TestWalHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "walhook.db")
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		PRAGMA journal_mode = WAL;
+		CREATE TABLE data (payload BLOB);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+
+	t.Run("auto checkpoint", func(t *testing.T) {
+		err := conn.Raw(func(driverConn any) error {
+			return driverConn.(*Conn).SetAutoCheckpoint(2)
+		})
+		if err != nil {
+			t.Fatalf("Failed to set auto checkpoint: %v", err)
+		}
+
+		for i := 0; i < 50; i++ {
+			if _, err := conn.ExecContext(context.Background(), "INSERT INTO data VALUES (randomblob(4096))"); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+		}
+
+		// Without checkpoints the log would hold at least one page per insert.
+		info, err := os.Stat(path + "-wal")
+		if err != nil {
+			t.Fatalf("Failed to stat wal file: %v", err)
+		}
+		if info.Size() > 10*4096 {
+			t.Errorf("Expected checkpoints to keep the wal file small, got %d bytes", info.Size())
+		}
+	})
+
+	t.Run("wal hook", func(t *testing.T) {
+		var calls, lastPages int
+		var lastDB string
+
+		err := conn.Raw(func(driverConn any) error {
+			return driverConn.(*Conn).RegisterWalHook(func(dbName string, pages int) error {
+				calls++
+				lastDB = dbName
+				lastPages = pages
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("Failed to register wal hook: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if _, err := conn.ExecContext(context.Background(), "INSERT INTO data VALUES (randomblob(4096))"); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+		}
+
+		if calls != 3 {
+			t.Errorf("Expected 3 wal hook calls, got %d", calls)
+		}
+		if lastDB != "main" || lastPages == 0 {
+			t.Errorf("Expected hook for main with pages, got %q with %d", lastDB, lastPages)
+		}
+
+		err = conn.Raw(func(driverConn any) error {
+			return driverConn.(*Conn).RegisterWalHook(nil)
+		})
+		if err != nil {
+			t.Fatalf("Failed to remove wal hook: %v", err)
+		}
+		if _, err := conn.ExecContext(context.Background(), "INSERT INTO data VALUES (1)"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected removed hook not to be called, got %d calls", calls)
+		}
+	})
+}
+
+func TestPragmas(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatalf("Failed to enable foreign keys: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+
+		cached := c.cache.len()
+		values, err := c.Pragmas("page_size", "journal_mode", "foreign_keys", "main.database_list")
+		if err != nil {
+			return err
+		}
+		if n := c.cache.len(); n != cached {
+			t.Errorf("Expected Pragmas to leave the statement cache at %d entries, got %d", cached, n)
+		}
+
+		expected := map[string]string{
+			"page_size":          "4096",
+			"journal_mode":       "memory",
+			"foreign_keys":       "1",
+			"main.database_list": "0",
+		}
+		for name, want := range expected {
+			if got := values[name]; got != want {
+				t.Errorf("Expected %s = %q, got %q", name, want, got)
+			}
+		}
+
+		if _, err := c.Pragmas("page_size; DROP TABLE x"); err == nil {
+			t.Error("Expected error for invalid pragma name")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read pragmas: %v", err)
+	}
+}
+
+func TestRegisterAuthorizer(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		CREATE TABLE audit (id INTEGER PRIMARY KEY, entry TEXT);
+		CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	// Prepare before registering to check cached statements are re-authorized.
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO audit (entry) VALUES (?)", "before"); err != nil {
+		t.Fatalf("Failed to insert before authorizer: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).RegisterAuthorizer(func(action int, arg1, arg2, dbName, trigger string) int {
+			switch action {
+			case SQLITE_INSERT, SQLITE_UPDATE, SQLITE_DELETE:
+				if arg1 == "audit" {
+					return SQLITE_DENY
+				}
+			}
+			return SQLITE_OK
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to register authorizer: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), "INSERT INTO audit (entry) VALUES (?)", "after")
+	var sqliteErr *Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != SQLITE_AUTH {
+		t.Errorf("Expected SQLITE_AUTH error inserting into audit, got %v", err)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO notes (body) VALUES (?)", "allowed"); err != nil {
+		t.Errorf("Expected insert into notes to succeed, got %v", err)
+	}
+
+	var count int
+	if err := conn.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM audit").Scan(&count); err != nil {
+		t.Fatalf("Failed to read audit: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 audit row, got %d", count)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).RegisterAuthorizer(nil)
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove authorizer: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO audit (entry) VALUES (?)", "after"); err != nil {
+		t.Errorf("Expected insert to succeed after removing authorizer, got %v", err)
+	}
+}
+
+func TestOpenMissingFileReadWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	tests := []struct {
+		name string
+		dsn  string
+		path string
+	}{
+		{"absolute", "file:" + filepath.Join(dir, "absolute.db") + "?mode=rw", filepath.Join(dir, "absolute.db")},
+		{"relative", "file:relative.db?mode=rw", filepath.Join(dir, "relative.db")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseDSN(tt.dsn)
+			if err != nil {
+				t.Fatalf("Failed to parse DSN: %v", err)
+			}
+			if cfg.flags&SQLITE_OPEN_CREATE != 0 {
+				t.Errorf("Expected mode=rw to omit SQLITE_OPEN_CREATE, got flags %#x", cfg.flags)
+			}
+
+			db, err := sql.Open("sqlite3", tt.dsn)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+
+			err = db.Ping()
+			var sqliteErr *Error
+			if !errors.As(err, &sqliteErr) || sqliteErr.Code != SQLITE_CANTOPEN {
+				t.Errorf("Expected SQLITE_CANTOPEN, got %v", err)
+			}
+
+			if _, err := os.Stat(tt.path); !os.IsNotExist(err) {
+				t.Errorf("Expected %s not to be created, got %v", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestRegisterTrace(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE logs (id INTEGER, msg TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	var traced, profiled []string
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		if err := c.RegisterTrace(func(sql string) {
+			traced = append(traced, sql)
+		}); err != nil {
+			return err
+		}
+		return c.RegisterProfile(func(sql string, nanos int64) {
+			profiled = append(profiled, sql)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to register callbacks: %v", err)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO logs VALUES (?, ?)", 42, "hello"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	expected := "INSERT INTO logs VALUES (42, 'hello')"
+	if sqlite3_expanded_sql == nil {
+		expected = "INSERT INTO logs VALUES (?, ?)"
+	}
+	if len(traced) != 1 || traced[0] != expected {
+		t.Errorf("Expected traced SQL [%q], got %q", expected, traced)
+	}
+	if len(profiled) != 1 || profiled[0] != expected {
+		t.Errorf("Expected profiled SQL [%q], got %q", expected, profiled)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).RegisterTrace(nil)
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove trace callback: %v", err)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("Failed to exec: %v", err)
+	}
+	if len(traced) != 1 {
+		t.Errorf("Expected no trace callbacks after removal, got %d more", len(traced)-1)
+	}
+	if len(profiled) != 2 {
+		t.Errorf("Expected profile callback to remain registered, got %d calls", len(profiled))
+	}
+}
+
+func TestCollatedQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			"single term",
+			"SELECT name FROM users ORDER BY name",
+			`SELECT name FROM users ORDER BY name COLLATE "natural"`,
+		},
+		{
+			"direction and limit",
+			"SELECT * FROM users ORDER BY last DESC, first ASC NULLS LAST LIMIT 10",
+			`SELECT * FROM users ORDER BY last COLLATE "natural" DESC, first COLLATE "natural" ASC NULLS LAST LIMIT 10`,
+		},
+		{
+			"explicit collation kept",
+			"SELECT * FROM users ORDER BY name COLLATE NOCASE, id",
+			`SELECT * FROM users ORDER BY name COLLATE NOCASE, id COLLATE "natural"`,
+		},
+		{
+			"expressions and subqueries",
+			"SELECT * FROM (SELECT lower(name) AS n FROM users ORDER BY lower(name)) ORDER BY n;",
+			`SELECT * FROM (SELECT lower(name) AS n FROM users ORDER BY lower(name) COLLATE "natural") ORDER BY n COLLATE "natural";`,
+		},
+		{
+			"strings and comments ignored",
+			"SELECT 'ORDER BY x' FROM t -- ORDER BY y\nORDER BY [order]",
+			"SELECT 'ORDER BY x' FROM t -- ORDER BY y\nORDER BY [order] COLLATE \"natural\"",
+		},
+		{
+			"no order by",
+			"SELECT * FROM users",
+			"SELECT * FROM users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollatedQuery("natural", tt.query); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	connector, err := NewConnector(Config{
+		DSN: ":memory:",
+		Collations: map[string]func(a, b string) int{
+			"length": func(a, b string) int {
+				if len(a) != len(b) {
+					return len(a) - len(b)
+				}
+				return strings.Compare(a, b)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE words (word TEXT);
+		INSERT INTO words VALUES ('banana'), ('fig'), ('apple'), ('kiwi');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	rows, err := db.Query(CollatedQuery("length", "SELECT word FROM words ORDER BY word"))
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			t.Fatalf("Failed to scan: %v", err)
+		}
+		words = append(words, word)
+	}
+
+	expected := []string{"fig", "kiwi", "apple", "banana"}
+	if strings.Join(words, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+}
+
+func TestTxnState(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "txn.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, n INTEGER); INSERT INTO items (n) VALUES (1)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if sqlite3_txn_state == nil {
+		t.Skip("SQLite older than 3.34 lacks sqlite3_txn_state")
+	}
+
+	state := func() int {
+		var s int
+		conn.Raw(func(driverConn any) error {
+			s = driverConn.(*Conn).TxnState("main")
+			return nil
+		})
+		return s
+	}
+
+	if s := state(); s != SQLITE_TXN_NONE {
+		t.Errorf("Expected SQLITE_TXN_NONE outside a transaction, got %d", s)
+	}
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if s := state(); s != SQLITE_TXN_NONE {
+		t.Errorf("Expected SQLITE_TXN_NONE before the first statement, got %d", s)
+	}
+
+	var n int
+	if err := tx.QueryRow("SELECT n FROM items").Scan(&n); err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	if s := state(); s != SQLITE_TXN_READ {
+		t.Errorf("Expected SQLITE_TXN_READ after SELECT, got %d", s)
+	}
+
+	if _, err := tx.Exec("UPDATE items SET n = n + 1"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if s := state(); s != SQLITE_TXN_WRITE {
+		t.Errorf("Expected SQLITE_TXN_WRITE after UPDATE, got %d", s)
+	}
+
+	conn.Raw(func(driverConn any) error {
+		if s := driverConn.(*Conn).TxnState("missing"); s != -1 {
+			t.Errorf("Expected -1 for an unknown schema, got %d", s)
+		}
+		return nil
+	})
+}
+
+func TestStmtReadonly(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		readonly bool
+	}{
+		{"SELECT * FROM items", true},
+		{"INSERT INTO items (name) VALUES (?)", false},
+		{"UPDATE items SET name = ?", false},
+		{"DELETE FROM items", false},
+		{"CREATE TABLE other (id INTEGER)", false},
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		for _, tt := range tests {
+			stmt, err := c.PrepareContext(context.Background(), tt.query)
+			if err != nil {
+				return err
+			}
+			if got := stmt.(*Stmt).Readonly(); got != tt.readonly {
+				t.Errorf("Readonly(%q) = %v, expected %v", tt.query, got, tt.readonly)
+			}
+			stmt.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+}
+
+func TestWALSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "walsize.db")
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE data (payload BLOB)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	walSize := func() int {
+		var frames int
+		err := conn.Raw(func(driverConn any) error {
+			var err error
+			frames, err = driverConn.(*Conn).WALSize()
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to get WAL size: %v", err)
+		}
+		return frames
+	}
+
+	if frames := walSize(); frames != 0 {
+		t.Errorf("Expected 0 frames outside WAL mode, got %d", frames)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "PRAGMA journal_mode = WAL"); err != nil {
+		t.Fatalf("Failed to enable WAL: %v", err)
+	}
+
+	// An open reader keeps the log from being reset by checkpoints.
+	reader, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin reader: %v", err)
+	}
+	defer reader.Rollback()
+	var count int
+	if err := reader.QueryRow("SELECT COUNT(*) FROM data").Scan(&count); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+
+	previous := walSize()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 10; j++ {
+			if _, err := conn.ExecContext(context.Background(), "INSERT INTO data VALUES (randomblob(4096))"); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+		}
+
+		frames := walSize()
+		if frames <= previous {
+			t.Errorf("Expected WAL frame count to grow past %d, got %d", previous, frames)
+		}
+		previous = frames
+	}
+}
+
+func TestChangeCounters(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE items (id INTEGER PRIMARY KEY, n INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	counters := func() (rowID int64, changes, total int) {
+		conn.Raw(func(driverConn any) error {
+			c := driverConn.(*Conn)
+			rowID, changes, total = c.LastInsertRowID(), c.Changes(), c.TotalChanges()
+			return nil
+		})
+		return
+	}
+
+	_, _, start := counters()
+
+	for i := 1; i <= 3; i++ {
+		if _, err := conn.ExecContext(context.Background(), "INSERT INTO items (n) VALUES (?)", i); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+
+		rowID, changes, total := counters()
+		if rowID != int64(i) {
+			t.Errorf("Expected last insert rowid %d, got %d", i, rowID)
+		}
+		if changes != 1 {
+			t.Errorf("Expected 1 change, got %d", changes)
+		}
+		if total != start+i {
+			t.Errorf("Expected total changes %d, got %d", start+i, total)
+		}
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "UPDATE items SET n = n * 2"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+
+	rowID, changes, total := counters()
+	if rowID != 3 {
+		t.Errorf("Expected last insert rowid to stay 3, got %d", rowID)
+	}
+	if changes != 3 {
+		t.Errorf("Expected 3 changes, got %d", changes)
+	}
+	if total != start+6 {
+		t.Errorf("Expected total changes %d, got %d", start+6, total)
+	}
+}
+
+func TestInAutocommit(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	autocommit := func() bool {
+		var in bool
+		conn.Raw(func(driverConn any) error {
+			in = driverConn.(*Conn).InAutocommit()
+			return nil
+		})
+		return in
+	}
+
+	if !autocommit() {
+		t.Error("Expected autocommit on a fresh connection")
+	}
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if autocommit() {
+		t.Error("Expected autocommit to be off after BEGIN")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if !autocommit() {
+		t.Error("Expected autocommit after Commit")
+	}
+}
+
+func TestTimeUnit(t *testing.T) {
+	const stored = 1700000000123
+
+	tests := []struct {
+		unit     string
+		expected time.Time
+	}{
+		{"", time.UnixMilli(stored).UTC()},
+		{"ms", time.UnixMilli(stored).UTC()},
+		{"us", time.UnixMicro(stored).UTC()},
+		{"ns", time.Unix(0, stored).UTC()},
+		{"s", time.Unix(stored, 0).UTC()},
+	}
+
+	for _, tt := range tests {
+		t.Run("unit="+tt.unit, func(t *testing.T) {
+			dsn := "file:timeunit.db?mode=memory"
+			if tt.unit != "" {
+				dsn += "&_time_unit=" + tt.unit
+			}
+
+			db, err := sql.Open("sqlite3", dsn)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+
+			if _, err := db.Exec("CREATE TABLE events (at TIMESTAMP)"); err != nil {
+				t.Fatalf("Failed to create table: %v", err)
+			}
+			if _, err := db.Exec("INSERT INTO events VALUES (?)", stored); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+
+			var got time.Time
+			if err := db.QueryRow("SELECT at FROM events").Scan(&got); err != nil {
+				t.Fatalf("Failed to scan: %v", err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	version, number := Version()
+	if version == "" {
+		t.Error("Expected a version string")
+	}
+	if number < 3008000 {
+		t.Errorf("Expected version number >= 3008000, got %d", number)
+	}
+
+	if !strings.HasPrefix(version, "3.") {
+		t.Errorf("Expected a 3.x version, got %q", version)
+	}
+
+	options := CompileOptions()
+	if len(options) == 0 {
+		t.Fatal("Expected compile options")
+	}
+	found := false
+	for _, opt := range options {
+		if strings.HasPrefix(opt, "THREADSAFE=") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected THREADSAFE among compile options, got %v", options)
+	}
+}
+
+func TestSetMinimumVersion(t *testing.T) {
+	SetMinimumVersion(99999999)
+	defer SetMinimumVersion(0)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Ping()
+	if err == nil {
+		t.Fatal("Expected open to fail below the minimum version")
+	}
+	if !strings.Contains(err.Error(), "older than the required minimum version 99999999") {
+		t.Errorf("Expected a clear minimum version error, got %v", err)
+	}
+
+	if version, _ := Version(); version == "" {
+		t.Error("Expected Version to work below the minimum version")
+	}
+
+	SetMinimumVersion(3008000)
+	if err := db.Ping(); err != nil {
+		t.Errorf("Expected open to succeed with a satisfied minimum, got %v", err)
+	}
+}
+
+// duplicateError is an application error the translator maps unique
+// constraint violations to.
+type duplicateError struct {
+	cause *Error
+}
+
+func (e *duplicateError) Error() string {
+	return "duplicate: " + e.cause.Message
+}
+
+func (e *duplicateError) Unwrap() error {
+	return e.cause
+}
+
+func TestSetErrorTranslator(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var translated int
+	err = conn.Raw(func(driverConn any) error {
+		driverConn.(*Conn).SetErrorTranslator(func(e *Error) error {
+			translated++
+			if e.ExtendedCode == SQLITE_CONSTRAINT_UNIQUE {
+				return &duplicateError{cause: e}
+			}
+			return nil
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to set translator: %v", err)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE users (email TEXT UNIQUE)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO users VALUES (?)", "a@example.com"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), "INSERT INTO users VALUES (?)", "a@example.com")
+	var dup *duplicateError
+	if !errors.As(err, &dup) {
+		t.Fatalf("Expected *duplicateError, got %T: %v", err, err)
+	}
+	var sqliteErr *Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != SQLITE_CONSTRAINT_UNIQUE {
+		t.Errorf("Expected the original *Error to remain reachable, got %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), "SELECT * FROM missing")
+	if errors.As(err, &dup) {
+		t.Errorf("Expected untranslated error for a missing table, got %v", err)
+	}
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != SQLITE_ERROR {
+		t.Errorf("Expected *Error when the translator returns nil, got %v", err)
+	}
+	if translated != 2 {
+		t.Errorf("Expected translator to run twice, got %d", translated)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		c.Close()
+		if err := c.Savepoint("sp"); err != driver.ErrBadConn {
+			t.Errorf("Expected driver.ErrBadConn on a closed connection, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to check closed connection: %v", err)
+	}
+	if translated != 2 {
+		t.Errorf("Expected ErrBadConn not to be translated, got %d calls", translated)
+	}
+}
+
+func TestTableInfoGeneratedColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		CREATE TABLE boxes (
+			id INTEGER PRIMARY KEY,
+			width REAL NOT NULL DEFAULT 1,
+			height REAL NOT NULL DEFAULT 1,
+			area REAL GENERATED ALWAYS AS (width * height) VIRTUAL,
+			label TEXT GENERATED ALWAYS AS ('box ' || id) STORED
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	var columns []ColumnInfo
+	err = conn.Raw(func(driverConn any) error {
+		var err error
+		columns, err = driverConn.(*Conn).TableInfo("boxes")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to get table info: %v", err)
+	}
+
+	expected := []ColumnInfo{
+		{CID: 0, Name: "id", Type: "INTEGER", PrimaryKey: 1},
+		{CID: 1, Name: "width", Type: "REAL", NotNull: true, Default: "1"},
+		{CID: 2, Name: "height", Type: "REAL", NotNull: true, Default: "1"},
+		{CID: 3, Name: "area", Type: "REAL", IsGenerated: true},
+		{CID: 4, Name: "label", Type: "TEXT", IsGenerated: true},
+	}
+	if len(columns) != len(expected) {
+		t.Fatalf("Expected %d columns, got %d: %+v", len(expected), len(columns), columns)
+	}
+	for i := range expected {
+		if columns[i] != expected[i] {
+			t.Errorf("Column %d: expected %+v, got %+v", i, expected[i], columns[i])
+		}
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*Conn).TableInfo("missing")
+		return err
+	})
+	if err == nil {
+		t.Error("Expected error for a missing table")
+	}
+}
+
+func TestSetLibraryPaths(t *testing.T) {
+	if err := loadSQLite3(); err != nil {
+		t.Fatalf("Failed to load library: %v", err)
+	}
+	original := libraryPath
+
+	resolved, err := findLibrary(original)
+	if err != nil {
+		t.Skipf("Cannot locate loaded library %s on disk: %v", original, err)
+	}
+
+	custom := filepath.Join(t.TempDir(), "libsqlite3-custom.so")
+	if err := os.Symlink(resolved, custom); err != nil {
+		t.Skipf("Cannot create symlink: %v", err)
+	}
+
+	SetLibraryPaths(custom)
+	defer SetLibraryPaths()
+	t.Setenv("SQLITE_PATH", strings.Join([]string{"/opt/a/libsqlite3.so", "/opt/b/libsqlite3.so"}, string(os.PathListSeparator)))
+
+	paths := searchPaths()
+	if len(paths) < 3 || paths[0] != custom || paths[1] != "/opt/a/libsqlite3.so" || paths[2] != "/opt/b/libsqlite3.so" {
+		t.Errorf("Expected custom path then SQLITE_PATH entries first, got %v", paths)
+	}
+
+	if err := loadLibrary(); err != nil {
+		t.Fatalf("Failed to load library from custom path: %v", err)
+	}
+	defer func() { libraryPath = original }()
+
+	if libraryPath != custom {
+		t.Errorf("Expected library loaded from %s, got %s", custom, libraryPath)
+	}
+	if version, _ := Version(); version == "" {
+		t.Error("Expected library from custom path to be usable")
+	}
+}
+
+// findLibrary resolves a library name as the dynamic loader would.
+func findLibrary(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return filepath.EvalSymlinks(name)
+	}
+	for _, dir := range []string{"/usr/lib/x86_64-linux-gnu", "/usr/lib/aarch64-linux-gnu", "/usr/lib64", "/usr/lib", "/usr/local/lib", "/opt/homebrew/lib"} {
+		if path, err := filepath.EvalSymlinks(filepath.Join(dir, name)); err == nil {
+			return path, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+func TestReloadAfterFailedLoad(t *testing.T) {
+	if err := loadSQLite3(); err != nil {
+		t.Fatalf("Failed to load library: %v", err)
+	}
+	original := libraryPath
+
+	resolved, err := findLibrary(original)
+	if err != nil {
+		t.Skipf("Cannot locate loaded library %s on disk: %v", original, err)
+	}
+
+	defaults := platformLibraryPaths
+	platformLibraryPaths = nil
+	t.Setenv("SQLITE_PATH", "")
+	defer func() {
+		platformLibraryPaths = defaults
+		SetLibraryPaths()
+		if err := ForceReload(); err != nil {
+			t.Errorf("Failed to restore library: %v", err)
+		}
+	}()
+
+	SetLibraryPaths(filepath.Join(t.TempDir(), "missing", "libsqlite3.so"))
+	if err := ForceReload(); err == nil {
+		t.Fatal("Expected reload from a missing path to fail")
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err == nil || !strings.Contains(err.Error(), "failed to load sqlite3 library") {
+		t.Fatalf("Expected load failure, got %v", err)
+	}
+
+	SetLibraryPaths(resolved)
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Expected load to succeed after fixing the path, got %v", err)
+	}
+	if libraryPath != resolved {
+		t.Errorf("Expected library loaded from %s, got %s", resolved, libraryPath)
+	}
+}
+
+func TestTableInfoHiddenColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE VIRTUAL TABLE docs USING fts5(title, body)"); err != nil {
+		t.Skipf("FTS5 not available: %v", err)
+	}
+
+	var columns []ColumnInfo
+	err = conn.Raw(func(driverConn any) error {
+		var err error
+		columns, err = driverConn.(*Conn).TableInfo("docs")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to get table info: %v", err)
+	}
+
+	hidden := make(map[string]bool)
+	for _, col := range columns {
+		hidden[col.Name] = col.Hidden
+		if col.IsGenerated {
+			t.Errorf("Expected %s not to be generated", col.Name)
+		}
+	}
+
+	expected := map[string]bool{"title": false, "body": false, "docs": true, "rank": true}
+	for name, want := range expected {
+		got, ok := hidden[name]
+		if !ok {
+			t.Errorf("Expected column %s, got %+v", name, columns)
+			continue
+		}
+		if got != want {
+			t.Errorf("Expected %s hidden=%v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestErrorStringExtendedCodes(t *testing.T) {
+	tests := []struct {
+		code     int
+		expected string
+	}{
+		{SQLITE_IOERR, "disk I/O error"},
+		{SQLITE_IOERR_WRITE, "disk I/O error: write failed"},
+		{SQLITE_IOERR_FSYNC, "disk I/O error: fsync failed"},
+		{SQLITE_IOERR | 99<<8, "disk I/O error"},
+		{SQLITE_BUSY_TIMEOUT, "database is locked: timed out"},
+		{SQLITE_CONSTRAINT_UNIQUE, "UNIQUE constraint failed"},
+		{SQLITE_CONSTRAINT_VTAB, "constraint failed"},
+		{1000, "unknown error code: 1000"},
+	}
+
+	for _, tt := range tests {
+		if got := errorString(tt.code); got != tt.expected {
+			t.Errorf("errorString(%s) = %q, expected %q", extendedErrorString(tt.code), got, tt.expected)
+		}
+	}
+
+	if errorString(SQLITE_IOERR_WRITE) == errorString(SQLITE_IOERR) {
+		t.Error("Expected extended I/O error to be more specific than its primary code")
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var prev, current, unknown int
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		prev = c.SetLimit(SQLITE_LIMIT_VARIABLE_NUMBER, 2)
+		current = c.SetLimit(SQLITE_LIMIT_VARIABLE_NUMBER, -1)
+		unknown = c.SetLimit(1000, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to set limit: %v", err)
+	}
+
+	if prev <= 2 {
+		t.Errorf("Expected previous variable limit above 2, got %d", prev)
+	}
+	if current != 2 {
+		t.Errorf("Expected variable limit 2, got %d", current)
+	}
+	if unknown != -1 {
+		t.Errorf("Expected -1 for unknown limit, got %d", unknown)
+	}
+
+	var sum int
+	if err := conn.QueryRowContext(context.Background(), "SELECT ? + ?", 1, 2).Scan(&sum); err != nil {
+		t.Fatalf("Failed to query within limit: %v", err)
+	}
+	if sum != 3 {
+		t.Errorf("Expected 3, got %d", sum)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "SELECT ? + ? + ?", 1, 2, 3); err == nil {
+		t.Error("Expected error for statement over the variable limit")
+	}
+}
+
+func TestLockedStmtMap(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:lockedmap.db?mode=memory&_stmt_cache_size=0&_stmt_map_size=16")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	stmt1, err := conn.PrepareContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+	stmt2, err := conn.PrepareContext(context.Background(), "SELECT 2")
+	if err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+
+	count := func() int {
+		var n int
+		err := conn.Raw(func(driverConn any) error {
+			c := driverConn.(*Conn)
+			if _, ok := c.stmts.(*LockedMap[uintptr, *Stmt]); !ok {
+				t.Errorf("Expected LockedMap, got %T", c.stmts)
+			}
+			n = c.stmts.Len()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to inspect connection: %v", err)
+		}
+		return n
+	}
+
+	if n := count(); n != 2 {
+		t.Errorf("Expected 2 tracked statements, got %d", n)
+	}
+	stmt1.Close()
+	if n := count(); n != 1 {
+		t.Errorf("Expected 1 tracked statement, got %d", n)
+	}
+	stmt2.Close()
+	if n := count(); n != 0 {
+		t.Errorf("Expected 0 tracked statements, got %d", n)
+	}
+}
+
+func BenchmarkStmtMap(b *testing.B) {
+	for _, size := range []int{0, 64} {
+		b.Run(fmt.Sprintf("map_size=%d", size), func(b *testing.B) {
+			db, err := sql.Open("sqlite3", fmt.Sprintf("file:benchmap%d.db?mode=memory&_stmt_cache_size=0&_stmt_map_size=%d", size, size))
+			if err != nil {
+				b.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			conn, err := db.Conn(context.Background())
+			if err != nil {
+				b.Fatalf("Failed to get connection: %v", err)
+			}
+			defer conn.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				stmt, err := conn.PrepareContext(context.Background(), "SELECT 1")
+				if err != nil {
+					b.Fatalf("Failed to prepare statement: %v", err)
+				}
+				stmt.Close()
+			}
+		})
+	}
+}
+
+func TestRowsColumnMetadata(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE a (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO a (name) VALUES ('one')`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		if sqlite3_column_origin_name == nil {
+			t.Skip("SQLite built without SQLITE_ENABLE_COLUMN_METADATA")
+		}
+
+		c := driverConn.(*Conn)
+		rows, err := c.QueryContext(context.Background(), "SELECT a.id AS x, name || '!' FROM a", nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		r := rows.(*Rows)
+		if err := r.Next(make([]driver.Value, 2)); err != nil {
+			return err
+		}
+
+		database, table, origin := r.ColumnMetadata(0)
+		if database != "main" || table != "a" || origin != "id" {
+			t.Errorf("Expected main.a.id, got %s.%s.%s", database, table, origin)
+		}
+
+		database, table, origin = r.ColumnMetadata(1)
+		if database != "" || table != "" || origin != "" {
+			t.Errorf("Expected empty metadata for expression, got %s.%s.%s", database, table, origin)
+		}
+
+		if _, _, origin := r.ColumnMetadata(5); origin != "" {
+			t.Errorf("Expected empty metadata for out of range index, got %q", origin)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to query column metadata: %v", err)
+	}
+}
+
+func TestColumnTypeNullable(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT NOT NULL, nickname TEXT);
+		INSERT INTO people (name) VALUES ('Ann')`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if sqlite3_table_column_metadata == nil {
+		t.Skip("SQLite built without SQLITE_ENABLE_COLUMN_METADATA")
+	}
+
+	rows, err := db.Query("SELECT id, name, nickname, upper(name) FROM people")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("Failed to get column types: %v", err)
+	}
+
+	expected := []bool{false, false, true, true}
+	for i, ct := range types {
+		nullable, ok := ct.Nullable()
+		if !ok {
+			t.Errorf("Expected nullability to be known for %s", ct.Name())
+		}
+		if nullable != expected[i] {
+			t.Errorf("Expected %s nullable=%v, got %v", ct.Name(), expected[i], nullable)
+		}
+	}
+}
+
+func TestOpenTemp(t *testing.T) {
+	db, err := OpenTemp(WithPragma("cache_size=-4000"))
+	if err != nil {
+		t.Fatalf("Failed to open temp database: %v", err)
+	}
+
+	if _, err := db.Exec("CREATE TABLE t (v TEXT); INSERT INTO t VALUES ('hello')"); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	var v string
+	if err := db.QueryRow("SELECT v FROM t").Scan(&v); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if v != "hello" {
+		t.Errorf("Expected hello, got %s", v)
+	}
+
+	pragmas := map[string]string{
+		"journal_mode": "wal",
+		"synchronous":  "0",
+		"temp_store":   "2",
+		"cache_size":   "-4000",
+	}
+	for name, expected := range pragmas {
+		var got string
+		if err := db.QueryRow("PRAGMA " + name).Scan(&got); err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if got != expected {
+			t.Errorf("Expected %s=%s, got %s", name, expected, got)
+		}
+	}
+
+	var path string
+	if err := db.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&path); err != nil {
+		t.Fatalf("Failed to get database path: %v", err)
+	}
+	if _, err := os.Stat(path + "-wal"); err != nil {
+		t.Errorf("Expected WAL file to exist: %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("Expected temp directory to be removed, got %v", err)
+	}
+}
+
+func TestColumnTypePrecisionScale(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE prices (
+		amount NUMERIC(18,4),
+		total DECIMAL(10),
+		spaced decimal( 8 , 3 ),
+		id INTEGER,
+		name VARCHAR(20)
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	rows, err := db.Query("SELECT amount, total, spaced, id, name FROM prices")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("Failed to get column types: %v", err)
+	}
+
+	tests := []struct {
+		precision int64
+		scale     int64
+		ok        bool
+	}{
+		{18, 4, true},
+		{10, 0, true},
+		{8, 3, true},
+		{0, 0, false},
+		{0, 0, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(types[i].Name(), func(t *testing.T) {
+			precision, scale, ok := types[i].DecimalSize()
+			if precision != tt.precision || scale != tt.scale || ok != tt.ok {
+				t.Errorf("Expected (%d, %d, %v), got (%d, %d, %v)", tt.precision, tt.scale, tt.ok, precision, scale, ok)
+			}
+		})
+	}
+}
+
+func TestColumnCase(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{"upper", []string{"ID", "NAME"}},
+		{"lower", []string{"id", "name"}},
+		{"preserve", []string{"id", "Name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", "file:columncase.db?mode=memory&_column_case="+tt.mode)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+
+			rows, err := db.Query("SELECT 1 AS id, 'x' AS Name")
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			defer rows.Close()
+
+			columns, err := rows.Columns()
+			if err != nil {
+				t.Fatalf("Failed to get columns: %v", err)
+			}
+			if strings.Join(columns, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, columns)
+			}
+		})
+	}
+}
+
+func TestBindUint64Overflow(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var got int64
+	if err := db.QueryRow("SELECT ?", uint64(math.MaxInt64)).Scan(&got); err != nil {
+		t.Fatalf("Failed to bind max int64 as uint64: %v", err)
+	}
+	if got != math.MaxInt64 {
+		t.Errorf("Expected %d, got %d", int64(math.MaxInt64), got)
+	}
+
+	err = db.QueryRow("SELECT ?", uint64(math.MaxUint64)).Scan(&got)
+	if err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Errorf("Expected overflow error, got %v", err)
+	}
+
+	t.Run("As text", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", "file:uint64text.db?mode=memory&_uint64_text=on")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		var value, typ string
+		if err := db.QueryRow("SELECT ?1, typeof(?1)", uint64(math.MaxUint64)).Scan(&value, &typ); err != nil {
+			t.Fatalf("Failed to bind uint64: %v", err)
+		}
+		if value != "18446744073709551615" || typ != "text" {
+			t.Errorf("Expected 18446744073709551615 as text, got %s as %s", value, typ)
+		}
+	})
+}
+
+func TestBulkInsertValues(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE points (x INTEGER, y INTEGER, label TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+
+	var inserts int
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		c.SetLimit(SQLITE_LIMIT_VARIABLE_NUMBER, 100)
+		return c.RegisterTrace(func(sql string) {
+			if strings.HasPrefix(sql, "INSERT") {
+				inserts++
+			}
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to configure connection: %v", err)
+	}
+	conn.Close()
+
+	rows := make([][]any, 10000)
+	for i := range rows {
+		rows[i] = []any{i, i * 2, fmt.Sprintf("p%d", i)}
+	}
+
+	if err := BulkInsertValues(db, "points", []string{"x", "y", "label"}, rows, 0); err != nil {
+		t.Fatalf("Failed to bulk insert: %v", err)
+	}
+
+	// 100 variables fit 33 rows of 3 columns per statement.
+	if expected := (10000 + 32) / 33; inserts != expected {
+		t.Errorf("Expected %d INSERT statements, got %d", expected, inserts)
+	}
+
+	var count, sumY int
+	if err := db.QueryRow("SELECT count(*), sum(y) FROM points").Scan(&count, &sumY); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 10000 {
+		t.Errorf("Expected 10000 rows, got %d", count)
+	}
+	if sumY != 9999*10000 {
+		t.Errorf("Expected sum %d, got %d", 9999*10000, sumY)
+	}
+
+	inserts = 0
+	if err := BulkInsertValues(db, "points", []string{"x", "y", "label"}, rows[:100], 10); err != nil {
+		t.Fatalf("Failed to bulk insert with chunk size: %v", err)
+	}
+	if inserts != 10 {
+		t.Errorf("Expected 10 INSERT statements, got %d", inserts)
+	}
+
+	if err := BulkInsertValues(db, "points", []string{"x", "y"}, rows[:1], 0); err == nil {
+		t.Error("Expected error for row with wrong number of values")
+	}
+}
+
+func TestBindBigNumbers(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE ledger (amount TEXT, ratio TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	amount := new(big.Int).Exp(big.NewInt(10), big.NewInt(40), nil)
+	amount.Neg(amount.Add(amount, big.NewInt(7)))
+	ratio := big.NewRat(1, 3)
+
+	if _, err := db.Exec("INSERT INTO ledger VALUES (?, ?)", amount, ratio); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO ledger VALUES (?, ?)", (*big.Int)(nil), (*big.Rat)(nil)); err != nil {
+		t.Fatalf("Failed to insert nil values: %v", err)
+	}
+
+	var amountText, ratioText string
+	if err := db.QueryRow("SELECT amount, ratio FROM ledger WHERE rowid = 1").Scan(&amountText, &ratioText); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	got, ok := new(big.Int).SetString(amountText, 10)
+	if !ok || got.Cmp(amount) != 0 {
+		t.Errorf("Expected %s, got %s", amount, amountText)
+	}
+	gotRatio, ok := new(big.Rat).SetString(ratioText)
+	if !ok || gotRatio.Cmp(ratio) != 0 {
+		t.Errorf("Expected %s, got %s", ratio.RatString(), ratioText)
+	}
+
+	var nulls int
+	if err := db.QueryRow("SELECT count(*) FROM ledger WHERE amount IS NULL AND ratio IS NULL").Scan(&nulls); err != nil {
+		t.Fatalf("Failed to count nulls: %v", err)
+	}
+	if nulls != 1 {
+		t.Errorf("Expected nil big numbers to bind NULL, got %d NULL rows", nulls)
+	}
+}
+
+func TestSetPreUpdateHook(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT, balance REAL);
+		INSERT INTO accounts VALUES (1, 'ann', 10.5)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	type change struct {
+		op                 int
+		db, table          string
+		oldRowid, newRowid int64
+		old, new           []driver.Value
+	}
+	var changes []change
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetPreUpdateHook(func(op int, db, table string, oldRowid, newRowid int64, old, new []driver.Value) {
+			changes = append(changes, change{op, db, table, oldRowid, newRowid, old, new})
+		})
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not supported") {
+			t.Skip(err)
+		}
+		t.Fatalf("Failed to set pre-update hook: %v", err)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "UPDATE accounts SET owner = 'bob', balance = 20 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "DELETE FROM accounts WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+
+	update := changes[0]
+	if update.op != SQLITE_UPDATE || update.db != "main" || update.table != "accounts" {
+		t.Errorf("Expected UPDATE on main.accounts, got op %d on %s.%s", update.op, update.db, update.table)
+	}
+	if update.oldRowid != 1 || update.newRowid != 1 {
+		t.Errorf("Expected rowids 1 -> 1, got %d -> %d", update.oldRowid, update.newRowid)
+	}
+	if fmt.Sprint(update.old) != "[1 ann 10.5]" {
+		t.Errorf("Expected old values [1 ann 10.5], got %v", update.old)
+	}
+	if fmt.Sprint(update.new) != "[1 bob 20]" {
+		t.Errorf("Expected new values [1 bob 20], got %v", update.new)
+	}
+
+	del := changes[1]
+	if del.op != SQLITE_DELETE || del.new != nil || fmt.Sprint(del.old) != "[1 bob 20]" {
+		t.Errorf("Expected DELETE with old values [1 bob 20], got op %d old %v new %v", del.op, del.old, del.new)
+	}
+}
+
+type jsonProfile struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type jsonPoint struct {
+	X, Y int
+}
+
+func (p jsonPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("[%d,%d]", p.X, p.Y)), nil
+}
+
+func TestJSONColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE docs (id INTEGER PRIMARY KEY, profile JSON, point JSON)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	profile := jsonProfile{Name: "ann", Tags: []string{"admin", "ops"}}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO docs (profile, point) VALUES (?, ?)", json.RawMessage(data), jsonPoint{X: 3, Y: 4})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	var raw json.RawMessage
+	var point, typ string
+	err = db.QueryRow("SELECT profile, point, typeof(profile) FROM docs").Scan(&raw, &point, &typ)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	if typ != "text" {
+		t.Errorf("Expected JSON stored as text, got %s", typ)
+	}
+	if point != "[3,4]" {
+		t.Errorf("Expected [3,4], got %s", point)
+	}
+
+	var got jsonProfile
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Failed to unmarshal %q: %v", raw, err)
+	}
+	if got.Name != profile.Name || strings.Join(got.Tags, ",") != "admin,ops" {
+		t.Errorf("Expected %+v, got %+v", profile, got)
+	}
+
+	var name string
+	if err := db.QueryRow("SELECT json_extract(profile, '$.name') FROM docs").Scan(&name); err != nil {
+		t.Fatalf("Failed to extract JSON field: %v", err)
+	}
+	if name != "ann" {
+		t.Errorf("Expected ann, got %s", name)
+	}
+}
+
+type staticValuer struct {
+	value driver.Value
+}
+
+func (v staticValuer) Value() (driver.Value, error) {
+	return v.value, nil
+}
+
+func TestValuerResultTypes(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+
+	tests := []struct {
+		name         string
+		value        driver.Value
+		expectedType string
+		expectedText string
+	}{
+		{"time", ts, "text", ts.Format(time.RFC3339Nano)},
+		{"bytes", []byte("abc"), "blob", "abc"},
+		{"empty bytes", []byte{}, "blob", ""},
+		{"nil bytes", []byte(nil), "null", ""},
+		{"nil", nil, "null", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typ string
+			var text sql.NullString
+			err := db.QueryRow("SELECT typeof(?1), CAST(?1 AS TEXT)", staticValuer{tt.value}).Scan(&typ, &text)
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			if typ != tt.expectedType {
+				t.Errorf("Expected type %s, got %s", tt.expectedType, typ)
+			}
+			if text.String != tt.expectedText {
+				t.Errorf("Expected %q, got %q", tt.expectedText, text.String)
+			}
+		})
+	}
+}
+
+func TestReadOnlyWALDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wal.db")
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`PRAGMA journal_mode=WAL;
+		CREATE TABLE t (v TEXT);
+		INSERT INTO t VALUES ('hello')`)
+	if err != nil {
+		t.Fatalf("Failed to create WAL database: %v", err)
+	}
+	db.Close()
+
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatalf("Failed to make directory read-only: %v", err)
+	}
+	defer os.Chmod(dir, 0o755)
+
+	db, err = sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var v string
+	if err := db.QueryRow("SELECT v FROM t").Scan(&v); err != nil {
+		t.Fatalf("Failed to read read-only WAL database: %v", err)
+	}
+	if v != "hello" {
+		t.Errorf("Expected hello, got %s", v)
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(path + suffix); !os.IsNotExist(err) {
+			t.Errorf("Expected no %s file, got %v", suffix, err)
+		}
+	}
+
+	// A -wal file left by a writer may hold committed rows the main file
+	// lacks, so the database must not be read as immutable.
+	if !dbConfigSupported {
+		return
+	}
+	os.Chmod(dir, 0o755)
+	writer, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	conn, err := writer.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*Conn).DBConfig(SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to disable checkpoint on close: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "UPDATE t SET v = 'updated'"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	conn.Close()
+	writer.Close()
+	os.Remove(path + "-shm")
+
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatalf("Failed to make directory read-only: %v", err)
+	}
+
+	db, err = sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.QueryRow("SELECT v FROM t").Scan(&v); err == nil && v != "updated" {
+		t.Errorf("Expected the pending WAL to be read or an error, got stale %s", v)
+	}
+}
+
+func TestImmutableRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(dir, "rel.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE t (v TEXT); INSERT INTO t VALUES ('hello')`)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	db.Close()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	// The read-only fallback reopens with the same immutable URI, so this
+	// also covers it when the test runs as root.
+	db, err = sql.Open("sqlite3", "file:rel.db?mode=ro&immutable=1")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var v string
+	if err := db.QueryRow("SELECT v FROM t").Scan(&v); err != nil {
+		t.Fatalf("Failed to read immutable database by relative path: %v", err)
+	}
+	if v != "hello" {
+		t.Errorf("Expected hello, got %s", v)
+	}
+}
+
+func TestQueryChecksum(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL, data BLOB);
+		INSERT INTO items (name, price, data) VALUES ('a', 1.5, x'0102'), ('b', NULL, NULL);
+		CREATE TABLE copy AS SELECT * FROM items`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	checksum := func(query string, args ...any) uint64 {
+		var sum uint64
+		err := conn.Raw(func(driverConn any) error {
+			var err error
+			sum, err = driverConn.(*Conn).QueryChecksum(query, args...)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to checksum %q: %v", query, err)
+		}
+		return sum
+	}
+
+	original := checksum("SELECT * FROM items ORDER BY id")
+	if again := checksum("SELECT * FROM items ORDER BY id"); again != original {
+		t.Errorf("Expected identical checksums, got %x and %x", original, again)
+	}
+	if other := checksum("SELECT * FROM copy ORDER BY id"); other != original {
+		t.Errorf("Expected identical data to hash the same, got %x and %x", original, other)
+	}
+	if filtered := checksum("SELECT * FROM items WHERE id >= ? ORDER BY id", 1); filtered != original {
+		t.Errorf("Expected bound query to hash the same, got %x and %x", original, filtered)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "UPDATE items SET price = 2.5 WHERE name = 'a'"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if changed := checksum("SELECT * FROM items ORDER BY id"); changed == original {
+		t.Error("Expected checksum to change after update")
+	}
+
+	if checksum("SELECT 1") == checksum("SELECT '1'") {
+		t.Error("Expected integer and text values to hash differently")
+	}
+	if checksum("SELECT 'ab', 'c'") == checksum("SELECT 'a', 'bc'") {
+		t.Error("Expected column boundaries to affect the checksum")
+	}
+}
+
+type namedStatus string
+
+func TestCheckNamedValueUnsupported(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	unsupported := []struct {
+		name  string
+		value any
+	}{
+		{"map", map[string]int{"a": 1}},
+		{"chan", make(chan int)},
+		{"struct", struct{ A int }{1}},
+		{"int slice", []int{1, 2}},
+	}
+
+	for _, tt := range unsupported {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Exec("SELECT ?", tt.value)
+			if err == nil {
+				t.Fatal("Expected error for unsupported argument")
+			}
+			if !strings.Contains(err.Error(), "unsupported type") || !strings.Contains(err.Error(), "argument 1") {
+				t.Errorf("Expected descriptive unsupported type error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("named string", func(t *testing.T) {
+		var got string
+		if err := db.QueryRow("SELECT ?", namedStatus("active")).Scan(&got); err != nil {
+			t.Fatalf("Failed to bind named string type: %v", err)
+		}
+		if got != "active" {
+			t.Errorf("Expected active, got %s", got)
+		}
+	})
+}
+
+func TestSetMemoryBudget(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE blobs (data BLOB)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetMemoryBudget(1 << 20)
+	})
+	if err != nil {
+		t.Fatalf("Failed to set memory budget: %v", err)
+	}
+
+	fill := `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO blobs SELECT randomblob(1024) FROM n`
+
+	if _, err := conn.ExecContext(context.Background(), fill, 100); err != nil {
+		t.Fatalf("Failed to insert within budget: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), fill, 10000)
+	if !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected memory budget error, got %v", err)
+	}
+	var sqliteErr *Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != SQLITE_INTERRUPT {
+		t.Errorf("Expected SQLITE_INTERRUPT, got %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).SetMemoryBudget(0)
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove memory budget: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), fill, 10000); err != nil {
+		t.Errorf("Expected insert to succeed without a budget, got %v", err)
+	}
+}
+
+func TestBindPointers(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	name := "ann"
+	active := true
+	age := 42
+	status := namedStatus("active")
+
+	tests := []struct {
+		name         string
+		value        any
+		expectedType string
+		expectedText string
+	}{
+		{"nil int", (*int)(nil), "null", ""},
+		{"int", &age, "integer", "42"},
+		{"string", &name, "text", "ann"},
+		{"bool", &active, "integer", "1"},
+		{"named string", &status, "text", "active"},
+		{"pointer to pointer", func() **string { p := &name; return &p }(), "text", "ann"},
+		{"nil NullString", (*sql.NullString)(nil), "null", ""},
+		{"nil time", (*time.Time)(nil), "null", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typ string
+			var text sql.NullString
+			if err := db.QueryRow("SELECT typeof(?1), CAST(?1 AS TEXT)", tt.value).Scan(&typ, &text); err != nil {
+				t.Fatalf("Failed to bind pointer: %v", err)
+			}
+			if typ != tt.expectedType || text.String != tt.expectedText {
+				t.Errorf("Expected %s %q, got %s %q", tt.expectedType, tt.expectedText, typ, text.String)
+			}
+		})
+	}
+
+	t.Run("direct bind", func(t *testing.T) {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		defer conn.Close()
+
+		err = conn.Raw(func(driverConn any) error {
+			c := driverConn.(*Conn)
+			stmt, _, err := c.prepare("SELECT ?, ?, ?, ?")
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+
+			if err := stmt.bindValue(1, (*string)(nil)); err != nil {
+				return err
+			}
+			if err := stmt.bindValue(2, (*sql.NullString)(nil)); err != nil {
+				return err
+			}
+			if err := stmt.bindValue(3, (*time.Time)(nil)); err != nil {
+				return err
+			}
+			return stmt.bindValue(4, &age)
+		})
+		if err != nil {
+			t.Errorf("Failed to bind pointers directly: %v", err)
+		}
+	})
+}
+
+func TestBindNullTypes(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		value        any
+		expectedType string
+		expectedText string
+	}{
+		{"NullString valid", sql.NullString{String: "hi", Valid: true}, "text", "hi"},
+		{"NullString invalid", sql.NullString{String: "hi"}, "null", ""},
+		{"NullInt64 valid", sql.NullInt64{Int64: 64, Valid: true}, "integer", "64"},
+		{"NullInt64 invalid", sql.NullInt64{Int64: 64}, "null", ""},
+		{"NullInt32 valid", sql.NullInt32{Int32: 32, Valid: true}, "integer", "32"},
+		{"NullInt32 invalid", sql.NullInt32{Int32: 32}, "null", ""},
+		{"NullInt16 valid", sql.NullInt16{Int16: 16, Valid: true}, "integer", "16"},
+		{"NullInt16 invalid", sql.NullInt16{Int16: 16}, "null", ""},
+		{"NullByte valid", sql.NullByte{Byte: 8, Valid: true}, "integer", "8"},
+		{"NullByte invalid", sql.NullByte{Byte: 8}, "null", ""},
+		{"NullFloat64 valid", sql.NullFloat64{Float64: 1.5, Valid: true}, "real", "1.5"},
+		{"NullFloat64 invalid", sql.NullFloat64{Float64: 1.5}, "null", ""},
+		{"NullBool valid", sql.NullBool{Bool: true, Valid: true}, "integer", "1"},
+		{"NullBool invalid", sql.NullBool{Bool: true}, "null", ""},
+		{"NullTime valid", sql.NullTime{Time: ts, Valid: true}, "text", ts.Format(time.RFC3339Nano)},
+		{"NullTime invalid", sql.NullTime{Time: ts}, "null", ""},
+		{"Null generic valid", sql.Null[string]{V: "generic", Valid: true}, "text", "generic"},
+		{"Null generic invalid", sql.Null[string]{V: "generic"}, "null", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typ string
+			var text sql.NullString
+			if err := db.QueryRow("SELECT typeof(?1), CAST(?1 AS TEXT)", tt.value).Scan(&typ, &text); err != nil {
+				t.Fatalf("Failed to bind: %v", err)
+			}
+			if typ != tt.expectedType || text.String != tt.expectedText {
+				t.Errorf("Expected %s %q, got %s %q", tt.expectedType, tt.expectedText, typ, text.String)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE blobs (data BLOB);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO blobs SELECT randomblob(1024) FROM n`)
+	if err != nil {
+		t.Fatalf("Failed to load rows: %v", err)
+	}
+
+	for _, op := range []int{SQLITE_STATUS_MEMORY_USED, SQLITE_STATUS_PAGECACHE_OVERFLOW, SQLITE_STATUS_MALLOC_COUNT} {
+		current, highwater, err := Status(op, false)
+		if err != nil {
+			t.Fatalf("Failed to read status %d: %v", op, err)
+		}
+		if current <= 0 {
+			t.Errorf("Expected status %d to be positive, got %d", op, current)
+		}
+		if highwater < current {
+			t.Errorf("Expected status %d highwater %d to be at least current %d", op, highwater, current)
+		}
+	}
+
+	if _, _, err := Status(SQLITE_STATUS_PAGECACHE_USED, false); err != nil {
+		t.Errorf("Failed to read page cache status: %v", err)
+	}
+
+	if _, _, err := Status(1000, false); err == nil {
+		t.Error("Expected error for unknown status op")
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var results []BatchResult
+	err = conn.Raw(func(driverConn any) error {
+		var err error
+		results, err = driverConn.(*Conn).ExecScript(`
+			CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, qty INTEGER);
+			INSERT INTO items (name, qty) VALUES ('a', 1), ('b', 2), ('c', ?);
+			UPDATE items SET qty = qty + 10 WHERE qty >= ?;
+			UPDATE items SET qty = 0 WHERE name = 'missing';
+			DELETE FROM items WHERE name = 'a';
+		`, 3, 2)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute script: %v", err)
+	}
+
+	expected := []BatchResult{
+		{SQL: "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, qty INTEGER);", RowsAffected: 0, LastInsertID: 0},
+		{SQL: "INSERT INTO items (name, qty) VALUES ('a', 1), ('b', 2), ('c', ?);", RowsAffected: 3, LastInsertID: 3},
+		{SQL: "UPDATE items SET qty = qty + 10 WHERE qty >= ?;", RowsAffected: 2, LastInsertID: 3},
+		{SQL: "UPDATE items SET qty = 0 WHERE name = 'missing';", RowsAffected: 0, LastInsertID: 3},
+		{SQL: "DELETE FROM items WHERE name = 'a';", RowsAffected: 1, LastInsertID: 3},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d: %+v", len(expected), len(results), results)
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Errorf("Statement %d: expected %+v, got %+v", i, expected[i], results[i])
+		}
+	}
+
+	var scriptErr error
+	err = conn.Raw(func(driverConn any) error {
+		results, scriptErr = driverConn.(*Conn).ExecScript(`INSERT INTO items (name) VALUES ('d'); INSERT INTO nope VALUES (1)`)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to run script: %v", err)
+	}
+	if scriptErr == nil {
+		t.Fatal("Expected error for failing statement")
+	}
+	if len(results) != 1 || results[0].RowsAffected != 1 {
+		t.Errorf("Expected the result of the statement before the failure, got %+v", results)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:querytimeout.db?mode=memory&_query_timeout=50ms")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	runaway := "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n"
+
+	start := time.Now()
+	var count int
+	err = db.QueryRow(runaway).Scan(&count)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected query to be interrupted promptly, took %v", elapsed)
+	}
+
+	_, err = db.Exec(runaway)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded error from Exec, got %v", err)
+	}
+
+	if err := db.QueryRow("SELECT 1").Scan(&count); err != nil {
+		t.Errorf("Expected quick query to succeed after a timeout, got %v", err)
+	}
+
+	// Every statement gets its own clock, including those stepped directly.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS t (x)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*Conn).QueryChecksum("WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000) SELECT i FROM n")
+		return err
+	})
+	if err != nil {
+		t.Errorf("Expected checksum after an earlier statement's deadline to succeed, got %v", err)
+	}
+}
+
+func TestDBConfig(t *testing.T) {
+	if !dbConfigSupported {
+		t.Skip("sqlite3_db_config cannot be called on this platform")
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	var enabled, current int
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		var err error
+		if enabled, err = c.DBConfig(SQLITE_DBCONFIG_DEFENSIVE, 1); err != nil {
+			return err
+		}
+		current, err = c.DBConfig(SQLITE_DBCONFIG_DEFENSIVE, -1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to enable defensive mode: %v", err)
+	}
+	if enabled != 1 || current != 1 {
+		t.Errorf("Expected defensive mode 1, got %d then %d", enabled, current)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "PRAGMA writable_schema = ON"); err != nil {
+		t.Fatalf("Failed to set writable_schema: %v", err)
+	}
+	_, err = conn.ExecContext(context.Background(), "UPDATE sqlite_master SET sql = sql WHERE name = 't'")
+	if err == nil {
+		t.Error("Expected schema modification to fail in defensive mode")
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*Conn).DBConfig(SQLITE_DBCONFIG_DEFENSIVE, 0)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to disable defensive mode: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "UPDATE sqlite_master SET sql = sql WHERE name = 't'"); err != nil {
+		t.Errorf("Expected schema modification to succeed outside defensive mode, got %v", err)
+	}
+}
+
+func TestAutoWAL(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("filesystem detection only runs on Linux and macOS")
+	}
+
+	journalMode := func(t *testing.T, path string) string {
+		db, err := sql.Open("sqlite3", "file:"+path+"?_auto_wal=on")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		var mode string
+		if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+			t.Fatalf("Failed to read journal mode: %v", err)
+		}
+		return mode
+	}
+
+	t.Run("local", func(t *testing.T) {
+		dir := t.TempDir()
+		if network, err := isNetworkFilesystem(dir); err != nil || network {
+			t.Skipf("Temp directory is not on a local filesystem: %v", err)
+		}
+
+		if mode := journalMode(t, filepath.Join(dir, "local.db")); mode != "wal" {
+			t.Errorf("Expected wal on a local filesystem, got %s", mode)
+		}
+	})
+
+	t.Run("network", func(t *testing.T) {
+		original := networkFilesystem
+		networkFilesystem = func(string) (bool, error) { return true, nil }
+		defer func() { networkFilesystem = original }()
+
+		if mode := journalMode(t, filepath.Join(t.TempDir(), "network.db")); mode != "delete" {
+			t.Errorf("Expected delete on a network filesystem, got %s", mode)
+		}
+	})
+}
+
+func TestTimeString(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var now TimeString
+	if err := db.QueryRow("SELECT datetime('now')").Scan(&now); err != nil {
+		t.Fatalf("Failed to scan datetime('now'): %v", err)
+	}
+	if !now.Valid {
+		t.Fatal("Expected valid time")
+	}
+	if diff := time.Since(now.Time); diff < -time.Minute || diff > time.Minute {
+		t.Errorf("Expected a time close to now, got %v", now.Time)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected time.Time
+	}{
+		{"text", "SELECT '2024-01-02 03:04:05'", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"unix", "SELECT 1704164645", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"julian", "SELECT julianday('2024-01-02 12:00:00')", time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts TimeString
+			if err := db.QueryRow(tt.query).Scan(&ts); err != nil {
+				t.Fatalf("Failed to scan: %v", err)
+			}
+			if !ts.Valid || !ts.Time.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v (valid %v)", tt.expected, ts.Time, ts.Valid)
+			}
+		})
+	}
+
+	var null TimeString
+	if err := db.QueryRow("SELECT NULL").Scan(&null); err != nil {
+		t.Fatalf("Failed to scan NULL: %v", err)
+	}
+	if null.Valid {
+		t.Error("Expected NULL to scan as invalid")
+	}
+
+	var bad TimeString
+	if err := db.QueryRow("SELECT 'not a time'").Scan(&bad); err == nil {
+		t.Error("Expected error scanning non-time text")
+	}
+}
+
+func TestSoftHeapLimit(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	previous := SoftHeapLimit(64 << 20)
+	defer SoftHeapLimit(previous)
+
+	if limit := SoftHeapLimit(-1); limit != 64<<20 {
+		t.Errorf("Expected limit %d, got %d", 64<<20, limit)
+	}
+
+	if _, err := db.Exec("CREATE TABLE t (v TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := db.Exec("INSERT INTO t VALUES (?)", strings.Repeat("x", 1000)); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	current, high := MemoryUsed()
+	if current <= 0 {
+		t.Errorf("Expected positive memory usage, got %d", current)
+	}
+	if high < current {
+		t.Errorf("Expected highwater >= %d, got %d", current, high)
+	}
+}
+
+func TestDBStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE t (v TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := conn.ExecContext(context.Background(), "INSERT INTO t VALUES (?)", strings.Repeat("x", 500)); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+
+		cacheUsed, _, err := c.DBStatus(SQLITE_DBSTATUS_CACHE_USED, false)
+		if err != nil {
+			return err
+		}
+		if cacheUsed <= 0 {
+			t.Errorf("Expected positive cache usage, got %d", cacheUsed)
+		}
+
+		schemaUsed, _, err := c.DBStatus(SQLITE_DBSTATUS_SCHEMA_USED, false)
+		if err != nil {
+			return err
+		}
+		if schemaUsed <= 0 {
+			t.Errorf("Expected positive schema usage, got %d", schemaUsed)
+		}
+
+		for _, op := range []int{SQLITE_DBSTATUS_STMT_USED, SQLITE_DBSTATUS_CACHE_HIT, SQLITE_DBSTATUS_CACHE_MISS} {
+			if _, _, err := c.DBStatus(op, true); err != nil {
+				return err
+			}
+		}
+
+		if _, _, err := c.DBStatus(-1, false); err == nil {
+			t.Error("Expected error for unknown status op")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+}
+
+func TestNonFiniteFloatBinding(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var typ string
+	if err := db.QueryRow("SELECT typeof(?)", math.NaN()).Scan(&typ); err != nil {
+		t.Fatalf("Failed to bind NaN: %v", err)
+	}
+	if typ != "null" {
+		t.Errorf("Expected NaN to bind as null, got %s", typ)
+	}
+
+	for _, f := range []float64{math.Inf(1), math.Inf(-1)} {
+		var got float64
+		if err := db.QueryRow("SELECT ?", f).Scan(&got); err != nil {
+			t.Fatalf("Failed to bind %v: %v", f, err)
+		}
+		if got != f {
+			t.Errorf("Expected %v, got %v", f, got)
+		}
+	}
+
+	t.Run("Strict", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", "file:strictfloat.db?mode=memory&_strict_float=on")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		for _, f := range []any{math.NaN(), math.Inf(1), float32(math.Inf(-1))} {
+			var got any
+			err := db.QueryRow("SELECT ?", f).Scan(&got)
+			if err == nil || !strings.Contains(err.Error(), "not finite") {
+				t.Errorf("Expected not finite error for %v, got %v", f, err)
+			}
+		}
+
+		var got float64
+		if err := db.QueryRow("SELECT ?", 1.5).Scan(&got); err != nil {
+			t.Fatalf("Failed to bind finite float: %v", err)
+		}
+		if got != 1.5 {
+			t.Errorf("Expected 1.5, got %v", got)
+		}
+	})
+}
+
+func TestStatementBalance(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:balance.db?mode=memory&_stmt_cache_size=0")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	preparedBefore, finalizedBefore := StatementBalance()
+
+	var leaked driver.Stmt
+	err = conn.Raw(func(driverConn any) error {
+		var err error
+		leaked, err = driverConn.(*Conn).Prepare("SELECT 1")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+
+	prepared, finalized := StatementBalance()
+	if open := (prepared - preparedBefore) - (finalized - finalizedBefore); open != 1 {
+		t.Errorf("Expected 1 open statement, got %d", open)
+	}
+
+	if err := leaked.Close(); err != nil {
+		t.Fatalf("Failed to close statement: %v", err)
+	}
+
+	prepared, finalized = StatementBalance()
+	if open := (prepared - preparedBefore) - (finalized - finalizedBefore); open != 0 {
+		t.Errorf("Expected balanced statements, got %d open", open)
+	}
+}
+
+func TestCacheAndMmapSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	db, err := sql.Open("sqlite3", "file:"+path+"?_cache_size=-4096&_mmap_size=1048576")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var cacheSize int
+	if err := db.QueryRow("PRAGMA cache_size").Scan(&cacheSize); err != nil {
+		t.Fatalf("Failed to read cache_size: %v", err)
+	}
+	if cacheSize != -4096 {
+		t.Errorf("Expected cache_size -4096, got %d", cacheSize)
+	}
+
+	var mmapSize int64
+	if err := db.QueryRow("PRAGMA mmap_size").Scan(&mmapSize); err != nil {
+		t.Fatalf("Failed to read mmap_size: %v", err)
+	}
+	// Builds with SQLITE_MAX_MMAP_SIZE=0 ignore the pragma.
+	if mmapSize != 1048576 && mmapSize != 0 {
+		t.Errorf("Expected mmap_size 1048576, got %d", mmapSize)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, name TEXT);
+		CREATE INDEX users_email ON users (email);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+
+		indexed, err := c.EstimateCost("SELECT id FROM users WHERE email = ?", "a@example.com")
+		if err != nil {
+			return err
+		}
+		unindexed, err := c.EstimateCost("SELECT id FROM users WHERE name = ?", "a")
+		if err != nil {
+			return err
+		}
+
+		if indexed.Opcodes == 0 || unindexed.Opcodes == 0 {
+			t.Errorf("Expected opcodes to be counted, got %d and %d", indexed.Opcodes, unindexed.Opcodes)
+		}
+		if len(indexed.Plan) == 0 {
+			t.Error("Expected a query plan")
+		}
+		if len(indexed.FullScans) != 0 {
+			t.Errorf("Expected no full scans for indexed query, got %v", indexed.FullScans)
+		}
+		if len(unindexed.FullScans) != 1 || unindexed.FullScans[0] != "users" {
+			t.Errorf("Expected full scan of users, got %v (plan %v)", unindexed.FullScans, unindexed.Plan)
+		}
+
+		if _, err := c.EstimateCost("SELECT * FROM missing"); err == nil {
+			t.Error("Expected error for missing table")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to estimate cost: %v", err)
+	}
+}
+
+func TestSecureDeleteAndTempStore(t *testing.T) {
+	tests := []struct {
+		dsn          string
+		secureDelete int
+		tempStore    int
+	}{
+		{"?_secure_delete=on&_temp_store=memory", 1, 2},
+		{"?_secure_delete=fast&_temp_store=file", 2, 1},
+		{"?_secure_delete=off&_temp_store=default", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secure.db")
+			db, err := sql.Open("sqlite3", "file:"+path+tt.dsn)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+
+			var secureDelete, tempStore int
+			if err := db.QueryRow("PRAGMA secure_delete").Scan(&secureDelete); err != nil {
+				t.Fatalf("Failed to read secure_delete: %v", err)
+			}
+			if err := db.QueryRow("PRAGMA temp_store").Scan(&tempStore); err != nil {
+				t.Fatalf("Failed to read temp_store: %v", err)
+			}
+
+			if secureDelete != tt.secureDelete {
+				t.Errorf("Expected secure_delete %d, got %d", tt.secureDelete, secureDelete)
+			}
+			if tempStore != tt.tempStore {
+				t.Errorf("Expected temp_store %d, got %d", tt.tempStore, tempStore)
+			}
+		})
+	}
+}
+
+func TestStatementOpLimit(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		driverConn.(*Conn).SetStatementOpLimit(10000)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to set op limit: %v", err)
+	}
+
+	const runaway = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 10000000)
+		SELECT count(*) FROM n`
+
+	var count int
+	err = conn.QueryRowContext(context.Background(), runaway).Scan(&count)
+	if !errors.Is(err, ErrStatementOpLimit) {
+		t.Fatalf("Expected ErrStatementOpLimit, got %v", err)
+	}
+
+	if err := conn.QueryRowContext(context.Background(), "SELECT 1").Scan(&count); err != nil {
+		t.Fatalf("Failed to run cheap query under op limit: %v", err)
+	}
+
+	// Statements stepped directly by the connection count their own ops
+	// rather than adding to the previous statement's.
+	const moderate = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 400)
+		SELECT i FROM n`
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		for i := 0; i < 5; i++ {
+			if _, err := c.QueryChecksum(moderate); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to checksum under op limit: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		driverConn.(*Conn).SetStatementOpLimit(0)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to remove op limit: %v", err)
+	}
+
+	const bounded = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100000)
+		SELECT count(*) FROM n`
+	if err := conn.QueryRowContext(context.Background(), bounded).Scan(&count); err != nil {
+		t.Fatalf("Failed to run query without op limit: %v", err)
+	}
+	if count != 100000 {
+		t.Errorf("Expected 100000, got %d", count)
+	}
+}
+
+func TestConnHandle(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), `
+		CREATE TABLE t (v INTEGER);
+		INSERT INTO t VALUES (1), (2), (3);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to set up table: %v", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		handle := driverConn.(*Conn).Handle()
+		if handle == 0 {
+			t.Fatal("Expected a non-zero handle")
+		}
+		if changes := sqlite3_changes(handle); changes != 3 {
+			t.Errorf("Expected 3 changes, got %d", changes)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to use raw connection: %v", err)
+	}
+}
+
+func TestAttachDSN(t *testing.T) {
+	dir := t.TempDir()
+
+	for i, name := range []string{"a.db", "b.db"} {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		_, err = db.Exec(fmt.Sprintf("CREATE TABLE items (id INTEGER PRIMARY KEY, source TEXT); INSERT INTO items (source) VALUES ('%s'), ('%s')", name, name))
+		db.Close()
+		if err != nil {
+			t.Fatalf("Failed to populate database %d: %v", i, err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_attach=aux1=%s&_attach=aux2=%s",
+		filepath.Join(dir, "main.db"), filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db"))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	// Every pooled connection has the attachments.
+	conns := make([]*sql.Conn, 2)
+	for i := range conns {
+		conns[i], err = db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		defer conns[i].Close()
+	}
+
+	for _, conn := range conns {
+		var count int
+		err := conn.QueryRowContext(context.Background(),
+			"SELECT count(*) FROM aux1.items a JOIN aux2.items b ON a.id = b.id").Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to run cross-schema query: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("Expected 2 joined rows, got %d", count)
+		}
+	}
+
+	var schemas []string
+	rows, err := conns[0].QueryContext(context.Background(), "SELECT name FROM pragma_database_list ORDER BY seq")
+	if err != nil {
+		t.Fatalf("Failed to list databases: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Failed to scan: %v", err)
+		}
+		schemas = append(schemas, name)
+	}
+	if got := strings.Join(schemas, ","); got != "main,aux1,aux2" {
+		t.Errorf("Expected main,aux1,aux2, got %s", got)
+	}
+}
+
+func TestConcurrentRawAccess(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var raw *Conn
+	err = conn.Raw(func(driverConn any) error {
+		raw = driverConn.(*Conn)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to get raw connection: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), `
+		CREATE TABLE t (v TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 2000)
+		INSERT INTO t SELECT 'row ' || i FROM n;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to populate table: %v", err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				raw.TotalChanges()
+				if _, _, err := raw.DBStatus(SQLITE_DBSTATUS_CACHE_USED, false); err != nil {
+					t.Errorf("Failed to read status: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		rows, err := conn.QueryContext(context.Background(), "SELECT v FROM t")
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		count := 0
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Failed to scan: %v", err)
+			}
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Failed to iterate rows: %v", err)
+		}
+		rows.Close()
+		if count != 2000 {
+			t.Errorf("Expected 2000 rows, got %d", count)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestThreadSafe(t *testing.T) {
+	mode := ThreadSafe()
+	if mode < 0 || mode > 2 {
+		t.Fatalf("Expected threading mode 0, 1 or 2, got %d", mode)
+	}
+
+	tests := []struct {
+		name       string
+		flags      int
+		threadsafe int
+		expected   int
+	}{
+		{"multi-thread default", SQLITE_OPEN_READWRITE, 2, SQLITE_OPEN_READWRITE | SQLITE_OPEN_FULLMUTEX},
+		{"multi-thread nomutex", SQLITE_OPEN_READWRITE | SQLITE_OPEN_NOMUTEX, 2, SQLITE_OPEN_READWRITE | SQLITE_OPEN_NOMUTEX},
+		{"serialized", SQLITE_OPEN_READWRITE, 1, SQLITE_OPEN_READWRITE},
+		{"single-thread", SQLITE_OPEN_READWRITE, 0, SQLITE_OPEN_READWRITE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mutexFlags(tt.flags, tt.threadsafe); got != tt.expected {
+				t.Errorf("Expected flags %#x, got %#x", tt.expected, got)
+			}
+		})
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Failed to open connection in threading mode %d: %v", mode, err)
+	}
+}
+
+func TestScanRawBytes(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE blobs (id INTEGER PRIMARY KEY, data BLOB);
+		INSERT INTO blobs (data) VALUES (x'0102'), (x'0304'), (x'');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to populate table: %v", err)
+	}
+
+	scanAll := func(scan func(rows *sql.Rows) error) {
+		rows, err := db.Query("SELECT data FROM blobs ORDER BY id")
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				t.Fatalf("Failed to scan: %v", err)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Failed to iterate rows: %v", err)
+		}
+	}
+
+	var raws []string
+	scanAll(func(rows *sql.Rows) error {
+		var raw sql.RawBytes
+		err := rows.Scan(&raw)
+		raws = append(raws, hex.EncodeToString(raw))
+		return err
+	})
+
+	var copies [][]byte
+	scanAll(func(rows *sql.Rows) error {
+		var data []byte
+		err := rows.Scan(&data)
+		copies = append(copies, data)
+		return err
+	})
+
+	if got := strings.Join(raws, ","); got != "0102,0304," {
+		t.Errorf("Expected 0102,0304, from RawBytes, got %s", got)
+	}
+	// Copies made by database/sql must survive later steps.
+	if len(copies) != 3 || !bytes.Equal(copies[0], []byte{1, 2}) || !bytes.Equal(copies[1], []byte{3, 4}) || len(copies[2]) != 0 {
+		t.Errorf("Expected copied blobs to be retained, got %v", copies)
+	}
+
+	large := bytes.Repeat([]byte{0xab}, 2<<20)
+	var got []byte
+	if err := db.QueryRow("SELECT ?", large).Scan(&got); err != nil {
+		t.Fatalf("Failed to scan large blob: %v", err)
+	}
+	if !bytes.Equal(got, large) {
+		t.Errorf("Expected %d byte blob, got %d bytes", len(large), len(got))
+	}
+
+	// Without _zero_copy, destinations database/sql does not copy for, such
+	// as json.RawMessage, keep their bytes after later steps.
+	_, err = db.Exec(`CREATE TABLE docs (id INTEGER PRIMARY KEY, doc JSON);
+		INSERT INTO docs (doc) VALUES ('{"a":1}'), ('{"b":2}')`)
+	if err != nil {
+		t.Fatalf("Failed to create docs: %v", err)
+	}
+	rows, err := db.Query("SELECT doc FROM docs ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query docs: %v", err)
+	}
+	var docs []string
+	var held []json.RawMessage
+	for rows.Next() {
+		var doc json.RawMessage
+		if err := rows.Scan(&doc); err != nil {
+			t.Fatalf("Failed to scan doc: %v", err)
+		}
+		held = append(held, doc)
+	}
+	rows.Close()
+	for _, doc := range held {
+		docs = append(docs, string(doc))
+	}
+	if got := strings.Join(docs, ","); got != `{"a":1},{"b":2}` {
+		t.Errorf("Expected retained documents, got %s", got)
+	}
+
+	// With _zero_copy, RawBytes still sees each row's blob.
+	zc, err := sql.Open("sqlite3", "file::memory:?_zero_copy=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer zc.Close()
+
+	var raw sql.RawBytes
+	zrows, err := zc.Query("SELECT x'0506'")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer zrows.Close()
+	if !zrows.Next() {
+		t.Fatal("Expected a row")
+	}
+	if err := zrows.Scan(&raw); err != nil || !bytes.Equal(raw, []byte{5, 6}) {
+		t.Errorf("Expected 0506 with _zero_copy, got %x (%v)", []byte(raw), err)
+	}
+}
+
+func BenchmarkScanBlob(b *testing.B) {
+	b.Run("Copy", func(b *testing.B) {
+		benchmarkScanBlob(b, ":memory:")
+	})
+	b.Run("ZeroCopy", func(b *testing.B) {
+		benchmarkScanBlob(b, "file::memory:?_zero_copy=on")
+	})
+}
+
+func benchmarkScanBlob(b *testing.B, dsn string) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE blobs (data BLOB);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000)
+		INSERT INTO blobs SELECT randomblob(4096) FROM n;
+	`)
+	if err != nil {
+		b.Fatalf("Failed to populate table: %v", err)
+	}
+
+	scan := func(b *testing.B, dest any) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rows, err := db.Query("SELECT data FROM blobs")
+			if err != nil {
+				b.Fatalf("Failed to query: %v", err)
+			}
+			for rows.Next() {
+				if err := rows.Scan(dest); err != nil {
+					b.Fatalf("Failed to scan: %v", err)
+				}
+			}
+			rows.Close()
+		}
+	}
+
+	b.Run("Bytes", func(b *testing.B) {
+		var data []byte
+		scan(b, &data)
+	})
+	b.Run("RawBytes", func(b *testing.B) {
+		var data sql.RawBytes
+		scan(b, &data)
+	})
+}
+
+func BenchmarkBindStrings(b *testing.B) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE t (c0, c1, c2, c3, c4, c5, c6, c7, c8, c9)"); err != nil {
+		b.Fatalf("Failed to create table: %v", err)
+	}
+
+	args := make([]any, 10)
+	for i := range args {
+		args[i] = fmt.Sprintf("value %d", i)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatalf("Failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		b.Fatalf("Failed to prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Start over every 100k rows to keep the table size bounded.
+		if i%100000 == 0 && i > 0 {
+			if _, err := tx.Exec("DELETE FROM t"); err != nil {
+				b.Fatalf("Failed to clear table: %v", err)
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			b.Fatalf("Failed to insert: %v", err)
+		}
+	}
+}
+
+func TestPreparedStatements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prepared.db")
+	setup, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := setup.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	setup.Close()
+
+	const byID = "SELECT name FROM users WHERE id = ?"
+	const insert = "INSERT INTO users (name) VALUES (?)"
+
+	connector, err := NewConnector(Config{DSN: "file:" + path}, WithPreparedStatements(byID, insert))
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	conns := make([]*sql.Conn, 2)
+	for i := range conns {
+		conns[i], err = db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		defer conns[i].Close()
+	}
+
+	for i, conn := range conns {
+		err := conn.Raw(func(driverConn any) error {
+			c := driverConn.(*Conn)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for _, query := range []string{byID, insert} {
+				stmt := c.cache.get(query)
+				if stmt == nil {
+					t.Errorf("Expected connection %d to have %q prepared", i, query)
+					continue
+				}
+				c.cache.put(stmt)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to inspect connection: %v", err)
+		}
+
+		prepared, _ := StatementBalance()
+		if _, err := conn.ExecContext(context.Background(), insert, "ann"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		var name string
+		if err := conn.QueryRowContext(context.Background(), byID, 1).Scan(&name); err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if after, _ := StatementBalance(); after != prepared {
+			t.Errorf("Expected cached statements to be reused, %d were prepared", after-prepared)
+		}
+	}
+
+	badConnector, err := NewConnector(Config{DSN: "file:" + path}, WithPreparedStatements("SELECT * FROM missing"))
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	bad := sql.OpenDB(badConnector)
+	defer bad.Close()
+	if err := bad.Ping(); err == nil {
+		t.Error("Expected error for statement that does not compile")
+	}
+}
+
+func TestStructArgs(t *testing.T) {
+	type Address struct {
+		City string `db:"city"`
+		Zip  string `db:"zip"`
+	}
+	type Audit struct {
+		CreatedAt time.Time `db:"created_at"`
+	}
+	type User struct {
+		Audit
+		Name     string         `db:"name"`
+		Age      int            `db:"age"`
+		Nickname *string        `db:"nickname"`
+		Email    sql.NullString `db:"email"`
+		Address  Address        `db:"address"`
+		Billing  *Address       `db:"billing"`
+		Password string         `db:"-"`
+		Notes    string
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (
+		name TEXT, age INTEGER, nickname TEXT, email TEXT,
+		city TEXT, billing_city TEXT, created_at DATETIME
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	user := User{
+		Audit:    Audit{CreatedAt: created},
+		Name:     "Ann",
+		Age:      42,
+		Email:    sql.NullString{String: "ann@example.com", Valid: true},
+		Address:  Address{City: "Utrecht", Zip: "3511"},
+		Password: "secret",
+	}
+
+	args := StructArgs(&user)
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = arg.(sql.NamedArg).Name
+	}
+	expected := "created_at,name,age,nickname,email,address_city,address_zip,billing_city,billing_zip"
+	if got := strings.Join(names, ","); got != expected {
+		t.Errorf("Expected arguments %s, got %s", expected, got)
+	}
+
+	// The query leaves some arguments, such as address_zip, unused.
+	_, err = db.Exec(`INSERT INTO users (name, age, nickname, email, city, billing_city, created_at)
+		VALUES (:name, @age, $nickname, :email, :address_city, :billing_city, :created_at)`, args...)
+	if err != nil {
+		t.Fatalf("Failed to insert from struct: %v", err)
+	}
+
+	var (
+		name, email, city string
+		age               int
+		nickname, billing sql.NullString
+		createdAt         time.Time
+	)
+	err = db.QueryRow("SELECT name, age, nickname, email, city, billing_city, created_at FROM users").
+		Scan(&name, &age, &nickname, &email, &city, &billing, &createdAt)
+	if err != nil {
+		t.Fatalf("Failed to read user: %v", err)
+	}
+	if name != "Ann" || age != 42 || email != "ann@example.com" || city != "Utrecht" || !createdAt.Equal(created) {
+		t.Errorf("Expected Ann, 42, ann@example.com, Utrecht, %v, got %s, %d, %s, %s, %v", created, name, age, email, city, createdAt)
+	}
+	if nickname.Valid || billing.Valid {
+		t.Errorf("Expected NULL nickname and billing city, got %v and %v", nickname, billing)
+	}
+
+	_, err = db.Exec("INSERT INTO users (name, age) VALUES (:name, :missing)", StructArgs(user)...)
+	if err == nil || !strings.Contains(err.Error(), ":missing") {
+		t.Errorf("Expected missing parameter error, got %v", err)
+	}
+
+	// Unlike named arguments, positional ones must all be used.
+	_, err = db.Exec("INSERT INTO users (name, age) VALUES (:name, ?)", sql.Named("name", "Bo"), 1, 2)
+	if err == nil || !strings.Contains(err.Error(), "argument 3 out of range") {
+		t.Errorf("Expected out of range error, got %v", err)
+	}
+
+	if args := StructArgs(42); args != nil {
+		t.Errorf("Expected nil for non-struct, got %v", args)
+	}
+}
+
+func BenchmarkPrepareLargeQuery(b *testing.B) {
+	db, err := sql.Open("sqlite3", "file:preparelarge.db?mode=memory&_stmt_cache_size=0")
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	var query strings.Builder
+	query.WriteString("SELECT 1 WHERE 1 IN (0")
+	for i := 1; query.Len() < 64<<10; i++ {
+		fmt.Fprintf(&query, ", %d", i)
+	}
+	query.WriteString(")")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, err := db.Prepare(query.String())
+		if err != nil {
+			b.Fatalf("Failed to prepare: %v", err)
+		}
+		stmt.Close()
+	}
+}
+
+func TestBindEmptyString(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var value, typ string
+	if err := db.QueryRow("SELECT ?1, typeof(?1)", "").Scan(&value, &typ); err != nil {
+		t.Fatalf("Failed to bind empty string: %v", err)
+	}
+	if value != "" || typ != "text" {
+		t.Errorf("Expected empty text, got %q as %s", value, typ)
+	}
+
+	const literal = "a string constant"
+	if err := db.QueryRow("SELECT ?", literal).Scan(&value); err != nil {
+		t.Fatalf("Failed to bind string constant: %v", err)
+	}
+	if value != literal {
+		t.Errorf("Expected %q, got %q", literal, value)
+	}
+}
+
+func BenchmarkBindText(b *testing.B) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	stmt, err := db.Prepare("SELECT length(?)")
+	if err != nil {
+		b.Fatalf("Failed to prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, size := range []int{16, 4 << 10, 1 << 20} {
+		text := strings.Repeat("x", size)
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var n int
+				if err := stmt.QueryRow(text).Scan(&n); err != nil {
+					b.Fatalf("Failed to query: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterFunctionPassthrough(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		// tag returns its argument untouched; copy round-trips it through Go.
+		if err := c.RegisterFunction("tag", 1, true, func(args []FuncArg) (any, error) {
+			return args[0], nil
+		}); err != nil {
+			return err
+		}
+		if err := c.RegisterFunction("copy", 1, true, func(args []FuncArg) (any, error) {
+			return args[0].Value(), nil
+		}); err != nil {
+			return err
+		}
+		return c.RegisterFunction("fail", -1, false, func(args []FuncArg) (any, error) {
+			return nil, fmt.Errorf("failed with %d arguments", len(args))
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to register functions: %v", err)
+	}
+
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"42", "integer"},
+		{"1.5", "real"},
+		{"'text'", "text"},
+		{"x'00ff'", "blob"},
+		{"NULL", "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			var typ string
+			var same bool
+			query := fmt.Sprintf("SELECT typeof(tag(%[1]s)), tag(%[1]s) IS %[1]s", tt.expr)
+			if err := conn.QueryRowContext(context.Background(), query).Scan(&typ, &same); err != nil {
+				t.Fatalf("Failed to call tag: %v", err)
+			}
+			if typ != tt.expected || !same {
+				t.Errorf("Expected %s passed through unchanged, got %s (same %v)", tt.expected, typ, same)
+			}
+		})
+	}
+
+	// json() marks its result with the JSON subtype, which only the
+	// passthrough preserves, so json_array embeds it as an object.
+	var tagged, copied string
+	err = conn.QueryRowContext(context.Background(),
+		`SELECT json_array(tag(json('{"a":1}'))), json_array(copy(json('{"a":1}')))`).Scan(&tagged, &copied)
+	if err != nil {
+		t.Fatalf("Failed to call functions: %v", err)
+	}
+	if tagged != `[{"a":1}]` {
+		t.Errorf("Expected subtype to be preserved, got %s", tagged)
+	}
+	if copied != `["{\"a\":1}"]` {
+		t.Errorf("Expected copied value to lose its subtype, got %s", copied)
+	}
+
+	var v any
+	err = conn.QueryRowContext(context.Background(), "SELECT fail(1, 2)").Scan(&v)
+	if err == nil || !strings.Contains(err.Error(), "failed with 2 arguments") {
+		t.Errorf("Expected function error, got %v", err)
+	}
+}
+
+func TestStatementAfterDrop(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), "CREATE TABLE t (v INTEGER); INSERT INTO t VALUES (1)")
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	const query = "SELECT v FROM t"
+	var v int
+	if err := conn.QueryRowContext(context.Background(), query).Scan(&v); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	cached := func() bool {
+		var ok bool
+		conn.Raw(func(driverConn any) error {
+			c := driverConn.(*Conn)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if stmt := c.cache.get(query); stmt != nil {
+				ok = true
+				c.cache.put(stmt)
+			}
+			return nil
+		})
+		return ok
+	}
+	if !cached() {
+		t.Fatal("Expected statement to be cached")
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "DROP TABLE t"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+
+	err = conn.QueryRowContext(context.Background(), query).Scan(&v)
+	if !errors.Is(err, ErrSchemaChanged) {
+		t.Fatalf("Expected ErrSchemaChanged, got %v", err)
+	}
+	var sqliteErr *Error
+	if !errors.As(err, &sqliteErr) || !strings.Contains(sqliteErr.Message, "no such table: t") {
+		t.Errorf("Expected *Error naming the dropped table, got %v", err)
+	}
+	if cached() {
+		t.Error("Expected invalidated statement to be evicted from the cache")
+	}
+
+	// Errors raised while running a valid statement are not schema changes.
+	_, err = conn.ExecContext(context.Background(), "SELECT abs(-9223372036854775807 - 1)")
+	if err == nil || errors.Is(err, ErrSchemaChanged) {
+		t.Errorf("Expected runtime error without ErrSchemaChanged, got %v", err)
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE points (x INTEGER, y INTEGER, label TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	rows := make([][]any, 10000)
+	for i := range rows {
+		rows[i] = []any{i, i * 2, fmt.Sprintf("p%d", i)}
+	}
+
+	tests := []struct {
+		name     string
+		rows     int
+		inserts  int
+		expected int
+	}{
+		// 99 variables fit exactly 33 rows of 3 columns per statement.
+		{"exact chunk", 33, 1, 33},
+		{"one past chunk", 34, 2, 34},
+		{"multiple chunks", 99, 3, 99},
+		{"large", 10000, (10000 + 32) / 33, 10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.ExecContext(context.Background(), "DELETE FROM points"); err != nil {
+				t.Fatalf("Failed to clear table: %v", err)
+			}
+
+			var n int64
+			inserts := 0
+			err := conn.Raw(func(driverConn any) error {
+				c := driverConn.(*Conn)
+				c.SetLimit(SQLITE_LIMIT_VARIABLE_NUMBER, 99)
+				if err := c.RegisterTrace(func(sql string) {
+					if strings.HasPrefix(sql, "INSERT") {
+						inserts++
+					}
+				}); err != nil {
+					return err
+				}
+				defer c.RegisterTrace(nil)
+
+				var err error
+				n, err = c.InsertBatch("points", []string{"x", "y", "label"}, rows[:tt.rows])
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Failed to insert batch: %v", err)
+			}
+
+			if n != int64(tt.expected) {
+				t.Errorf("Expected %d rows inserted, got %d", tt.expected, n)
+			}
+			if inserts != tt.inserts {
+				t.Errorf("Expected %d INSERT statements, got %d", tt.inserts, inserts)
+			}
+
+			var count, sumX int
+			if err := conn.QueryRowContext(context.Background(), "SELECT count(*), coalesce(sum(x), 0) FROM points").Scan(&count, &sumX); err != nil {
+				t.Fatalf("Failed to count rows: %v", err)
+			}
+			if count != tt.expected {
+				t.Errorf("Expected %d rows, got %d", tt.expected, count)
+			}
+			if expected := tt.expected * (tt.expected - 1) / 2; sumX != expected {
+				t.Errorf("Expected sum %d, got %d", expected, sumX)
+			}
+		})
+	}
+
+	// A failing chunk rolls back the chunks inserted before it.
+	_, err = conn.ExecContext(context.Background(), "DELETE FROM points; CREATE UNIQUE INDEX points_x ON points (x)")
+	if err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	duplicate := append(slices.Clone(rows[:50]), rows[0])
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*Conn).InsertBatch("points", []string{"x", "y", "label"}, duplicate)
+		return err
+	})
+	if err == nil {
+		t.Fatal("Expected error for duplicate key")
+	}
+
+	var count int
+	if err := conn.QueryRowContext(context.Background(), "SELECT count(*) FROM points").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected failed batch to be rolled back, got %d rows", count)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*Conn).InsertBatch("points", []string{"x", "y"}, rows[:1])
+		return err
+	})
+	if err == nil {
+		t.Error("Expected error for row with wrong number of values")
+	}
+}
+
+func TestChanges64(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE t (v INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	if sqlite3_changes64 == nil || sqlite3_total_changes64 == nil {
+		t.Skip("SQLite library lacks sqlite3_changes64")
+	}
+
+	// Stand in for a statement that changed more rows than fit in 32 bits.
+	const large = int64(1) << 33
+	changes64, totalChanges64 := sqlite3_changes64, sqlite3_total_changes64
+	sqlite3_changes64 = func(uintptr) int64 { return large }
+	sqlite3_total_changes64 = func(uintptr) int64 { return large + 1 }
+	defer func() { sqlite3_changes64, sqlite3_total_changes64 = changes64, totalChanges64 }()
+
+	res, err := db.Exec("INSERT INTO t VALUES (1)")
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != large {
+		t.Errorf("Expected %d rows affected, got %d", large, n)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		if n := c.Changes(); int64(n) != large {
+			t.Errorf("Expected Changes %d, got %d", large, n)
+		}
+		if n := c.TotalChanges(); int64(n) != large+1 {
+			t.Errorf("Expected TotalChanges %d, got %d", large+1, n)
+		}
+		return nil
+	})
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"100%", `100\%`},
+		{"snake_case", `snake\_case`},
+		{`C:\temp`, `C:\\temp`},
+		{`%_\`, `\%\_\\`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := EscapeLike(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE files (name TEXT);
+		INSERT INTO files VALUES ('100% done'), ('1000 done'), ('snake_case'), ('snakeXcase'), ('dir\file'), ('dirXfile')`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	for term, expected := range map[string]string{"0%": "100% done", "e_c": "snake_case", `r\f`: `dir\file`} {
+		rows, err := db.Query(`SELECT name FROM files WHERE name LIKE ? ESCAPE '\'`, "%"+EscapeLike(term)+"%")
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatalf("Failed to scan: %v", err)
+			}
+			names = append(names, name)
+		}
+		rows.Close()
+
+		if len(names) != 1 || names[0] != expected {
+			t.Errorf("Expected %q to match only %q, got %q", term, expected, names)
+		}
+	}
+}
+
+func TestBusyRetry(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "busy.db")
+
+	locker, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer locker.Close()
+
+	if _, err := locker.Exec("CREATE TABLE t (v INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	lockConn, err := locker.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer lockConn.Close()
+
+	// lock holds an exclusive lock, blocking readers and writers, for d.
+	lock := func(d time.Duration) {
+		if _, err := lockConn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+			t.Fatalf("Failed to lock database: %v", err)
+		}
+		time.AfterFunc(d, func() {
+			lockConn.ExecContext(context.Background(), "COMMIT")
+		})
+	}
+
+	open := func(params string) *sql.DB {
+		db, err := sql.Open("sqlite3", "file:"+dbPath+"?_busy_timeout=1"+params)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		db.SetMaxOpenConns(1)
+		if err := db.Ping(); err != nil {
+			t.Fatalf("Failed to ping: %v", err)
+		}
+		return db
+	}
+
+	isBusy := func(err error) bool {
+		var sqliteErr *Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code == SQLITE_BUSY
+	}
+
+	plain := open("")
+	defer plain.Close()
+	retrying := open("&_retry_busy=10&_retry_backoff=20ms")
+	defer retrying.Close()
+
+	lock(100 * time.Millisecond)
+	if _, err := plain.Exec("INSERT INTO t VALUES (1)"); !isBusy(err) {
+		t.Errorf("Expected ErrBusy without retries, got %v", err)
+	}
+	if _, err := retrying.Exec("INSERT INTO t VALUES (1)"); err != nil {
+		t.Errorf("Expected insert to succeed after retrying, got %v", err)
+	}
+
+	lock(100 * time.Millisecond)
+	var count int
+	if err := retrying.QueryRow("SELECT count(*) FROM t").Scan(&count); err != nil {
+		t.Errorf("Expected query to succeed after retrying, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 row, got %d", count)
+	}
+
+	// Statements inside a transaction are not retried.
+	tx, err := retrying.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	lock(100 * time.Millisecond)
+	if _, err := tx.Exec("INSERT INTO t VALUES (2)"); !isBusy(err) {
+		t.Errorf("Expected ErrBusy inside a transaction, got %v", err)
+	}
+	tx.Rollback()
+	time.Sleep(150 * time.Millisecond)
+
+	// SetBusyRetry adjusts a single connection.
+	conn, err := plain.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+	conn.Raw(func(driverConn any) error {
+		driverConn.(*Conn).SetBusyRetry(10, 20*time.Millisecond)
+		return nil
+	})
+
+	lock(100 * time.Millisecond)
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO t VALUES (3)"); err != nil {
+		t.Errorf("Expected insert to succeed after SetBusyRetry, got %v", err)
+	}
+
+	if _, err := parseDSN("file:test.db?_retry_busy=-1"); err == nil {
+		t.Error("Expected error for negative _retry_busy")
+	}
+}
+
+func TestContextBusyTimeout(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "busy.db")
+
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE t (v INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	locker, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer locker.Close()
+
+	lockConn, err := locker.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer lockConn.Close()
+
+	if _, err := lockConn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("Failed to lock database: %v", err)
+	}
+	unlock := time.AfterFunc(300*time.Millisecond, func() {
+		lockConn.ExecContext(context.Background(), "COMMIT")
+	})
+	defer unlock.Stop()
+
+	ctx := ContextWithBusyTimeout(context.Background(), 20*time.Millisecond)
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"exec", func() error {
+			_, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+			return err
+		}},
+		{"query", func() error {
+			var n int
+			return db.QueryRowContext(ctx, "SELECT count(*) FROM t").Scan(&n)
+		}},
+		{"prepare", func() error {
+			// A new connection loads the schema when it first prepares.
+			fresh, err := sql.Open("sqlite3", "file:"+dbPath+"?_busy_timeout=5000")
+			if err != nil {
+				return err
+			}
+			defer fresh.Close()
+			var n int
+			return fresh.QueryRowContext(ctx, "SELECT count(*) FROM t").Scan(&n)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			err := tt.run()
+			var sqliteErr *Error
+			if !errors.As(err, &sqliteErr) || sqliteErr.Code != SQLITE_BUSY {
+				t.Fatalf("Expected SQLITE_BUSY, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+				t.Errorf("Expected to fail fast, took %v", elapsed)
+			}
+		})
+	}
+
+	// Without the override the default timeout waits for the lock.
+	if _, err := db.Exec("INSERT INTO t VALUES (2)"); err != nil {
+		t.Errorf("Expected insert to wait for the lock, got %v", err)
+	}
+
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("Failed to read busy timeout: %v", err)
+	}
+	if timeout != 5000 {
+		t.Errorf("Expected busy timeout 5000 to be restored, got %d", timeout)
+	}
+}
+
+// wordTokenizer splits text into runs of letters and digits, lowercased, and
+// with the stem argument drops a trailing "s".
+type wordTokenizer struct {
+	stem bool
+}
+
+func (w wordTokenizer) Tokenize(text string, flags int, emit func(token string, start, end int) error) error {
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && (text[i] >= 'a' && text[i] <= 'z' || text[i] >= 'A' && text[i] <= 'Z' || text[i] >= '0' && text[i] <= '9') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+
+		token := strings.ToLower(text[start:i])
+		if w.stem && len(token) > 1 {
+			token = strings.TrimSuffix(token, "s")
+		}
+		if err := emit(token, start, i); err != nil {
+			return err
+		}
+		start = -1
+	}
+	return nil
+}
+
+func TestRegisterFTS5Tokenizer(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var factoryArgs [][]string
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).RegisterFTS5Tokenizer("words", func(args []string) (Tokenizer, error) {
+			factoryArgs = append(factoryArgs, args)
+			switch {
+			case len(args) == 0:
+				return wordTokenizer{}, nil
+			case len(args) == 1 && args[0] == "stem":
+				return wordTokenizer{stem: true}, nil
+			}
+			return nil, fmt.Errorf("unknown arguments %q", args)
+		})
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "FTS5") {
+			t.Skipf("FTS5 not available: %v", err)
+		}
+		t.Fatalf("Failed to register tokenizer: %v", err)
+	}
+
+	_, err = conn.ExecContext(context.Background(), `CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = 'words stem');
+		INSERT INTO docs VALUES ('Cats-and-Dogs'), ('A bird');`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if len(factoryArgs) == 0 || !slices.Equal(factoryArgs[0], []string{"stem"}) {
+		t.Errorf("Expected factory arguments [stem], got %q", factoryArgs)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"cat", []string{"[Cats]-and-Dogs"}},
+		{"DOG", []string{"Cats-and-[Dogs]"}},
+		{"birds", []string{"A [bird]"}},
+		{"fish", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rows, err := conn.QueryContext(context.Background(), "SELECT highlight(docs, 0, '[', ']') FROM docs WHERE docs MATCH ?", tt.query)
+			if err != nil {
+				t.Fatalf("Failed to search: %v", err)
+			}
+			defer rows.Close()
+
+			var got []string
+			for rows.Next() {
+				var s string
+				if err := rows.Scan(&s); err != nil {
+					t.Fatalf("Failed to scan: %v", err)
+				}
+				got = append(got, s)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	_, err = conn.ExecContext(context.Background(), "CREATE VIRTUAL TABLE bad USING fts5(body, tokenize = 'words bogus')")
+	if err == nil {
+		t.Error("Expected error for a tokenizer factory failure")
+	}
+}
+
+func TestTimeFormatSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:?_time_format=sqlite")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE events (at DATETIME)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	at := time.Date(2024, 3, 15, 14, 30, 45, 123456789, time.FixedZone("CET", 3600))
+	if _, err := db.Exec("INSERT INTO events VALUES (?)", at); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	var raw, datetime string
+	var before bool
+	err = db.QueryRow("SELECT CAST(at AS TEXT), datetime(at), at < datetime('2024-03-15 13:30:46') FROM events").Scan(&raw, &datetime, &before)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if raw != "2024-03-15 13:30:45.123456" {
+		t.Errorf("Expected stored text 2024-03-15 13:30:45.123456, got %s", raw)
+	}
+	if datetime != "2024-03-15 13:30:45" {
+		t.Errorf("Expected datetime 2024-03-15 13:30:45, got %s", datetime)
+	}
+	if !before {
+		t.Error("Expected stored time to compare against datetime() output")
+	}
+
+	var got time.Time
+	if err := db.QueryRow("SELECT at FROM events").Scan(&got); err != nil {
+		t.Fatalf("Failed to scan time: %v", err)
+	}
+	if expected := at.Truncate(time.Microsecond); !got.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if _, err := parseDSN("file:test.db?_time_format=unix"); err == nil {
+		t.Error("Expected error for unknown _time_format")
+	}
+}
+
+func TestScanValues(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE mixed (i INTEGER, f REAL, s TEXT, b BLOB, d DATETIME, ok BOOLEAN, n TEXT);
+		INSERT INTO mixed VALUES (42, 1.5, 'text', x'0102', '2024-03-15 14:30:45', 1, NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	rows, err := db.Query("SELECT * FROM mixed")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatalf("Failed to read row: %v", rows.Err())
+	}
+	values, err := ScanValues(rows)
+	if err != nil {
+		t.Fatalf("Failed to scan values: %v", err)
+	}
+
+	expected := []any{
+		int64(42),
+		1.5,
+		"text",
+		[]byte{1, 2},
+		time.Date(2024, 3, 15, 14, 30, 45, 0, time.UTC),
+		true,
+		nil,
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("Expected %d values, got %d", len(expected), len(values))
+	}
+	for i, want := range expected {
+		got := values[i]
+		if fmt.Sprintf("%T", got) != fmt.Sprintf("%T", want) {
+			t.Errorf("Column %d: expected type %T, got %T", i, want, got)
+			continue
+		}
+		switch want := want.(type) {
+		case []byte:
+			if !bytes.Equal(got.([]byte), want) {
+				t.Errorf("Column %d: expected %v, got %v", i, want, got)
+			}
+		case time.Time:
+			if !got.(time.Time).Equal(want) {
+				t.Errorf("Column %d: expected %v, got %v", i, want, got)
+			}
+		default:
+			if got != want {
+				t.Errorf("Column %d: expected %v, got %v", i, want, got)
+			}
+		}
+	}
+}
+
+func TestOpenTimeout(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "locked.db")
+
+	locker, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer locker.Close()
+
+	if _, err := locker.Exec("CREATE TABLE t (v INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	lockConn, err := locker.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer lockConn.Close()
+
+	if _, err := lockConn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("Failed to lock database: %v", err)
+	}
+
+	open := func(timeout time.Duration) *sql.DB {
+		connector, err := NewConnector(Config{DSN: "file:" + dbPath + "?_busy_timeout=5000"}, WithOpenTimeout(timeout))
+		if err != nil {
+			t.Fatalf("Failed to create connector: %v", err)
+		}
+		return sql.OpenDB(connector)
+	}
+
+	db := open(100 * time.Millisecond)
+	defer db.Close()
+
+	start := time.Now()
+	err = db.Ping()
+	var sqliteErr *Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != SQLITE_BUSY {
+		t.Errorf("Expected SQLITE_BUSY opening a locked database, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected open to give up after about 100ms, took %v", elapsed)
+	}
+
+	time.AfterFunc(200*time.Millisecond, func() {
+		lockConn.ExecContext(context.Background(), "COMMIT")
+	})
+
+	db = open(5 * time.Second)
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Expected open to succeed once the lock is released, got %v", err)
+	}
+
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("Failed to read busy timeout: %v", err)
+	}
+	if timeout != 5000 {
+		t.Errorf("Expected busy timeout 5000 after open, got %d", timeout)
+	}
+}
+
+func TestNextResultSet(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER, name TEXT);
+		INSERT INTO users VALUES (1, 'alice'), (2, 'bob')`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// readSets collects every result set as rows of formatted values.
+	readSets := func(rows *sql.Rows) [][]string {
+		var sets [][]string
+		for {
+			columns, err := rows.Columns()
+			if err != nil {
+				t.Fatalf("Failed to get columns: %v", err)
+			}
+			set := []string{strings.Join(columns, ",")}
+			for rows.Next() {
+				values, err := ScanValues(rows)
+				if err != nil {
+					t.Fatalf("Failed to scan: %v", err)
+				}
+				set = append(set, fmt.Sprint(values...))
+			}
+			sets = append(sets, set)
+			if !rows.NextResultSet() {
+				break
+			}
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Failed to iterate result sets: %v", err)
+		}
+		return sets
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		args     []any
+		expected [][]string
+	}{
+		{
+			name:     "two selects",
+			query:    "SELECT name FROM users ORDER BY id; SELECT count(*) AS n FROM users",
+			expected: [][]string{{"name", "alice", "bob"}, {"n", "2"}},
+		},
+		{
+			name:     "positional arguments",
+			query:    "SELECT name FROM users WHERE id = ?; SELECT ? + ? AS sum;",
+			args:     []any{2, 3, 4},
+			expected: [][]string{{"name", "bob"}, {"sum", "7"}},
+		},
+		{
+			name:     "statements without columns are skipped",
+			query:    "SELECT count(*) AS before FROM users; INSERT INTO users VALUES (3, 'carol'); SELECT count(*) AS after FROM users",
+			expected: [][]string{{"before", "2"}, {"after", "3"}},
+		},
+		{
+			name:     "single statement",
+			query:    "SELECT id FROM users WHERE id = 1 -- trailing comment",
+			expected: [][]string{{"id", "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.Query(tt.query, tt.args...)
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			defer rows.Close()
+
+			got := readSets(rows)
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// A later statement's missing arguments surface when advancing to it.
+	rows, err := db.Query("SELECT ?; SELECT ?", 1)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	if rows.NextResultSet() || rows.Err() == nil {
+		t.Error("Expected error for missing arguments of a later statement")
+	}
+
+	// Leftover arguments are an error, as they are for Exec.
+	rows, err = db.Query("SELECT 1; SELECT 2", 5)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	defer rows.Close()
+	for rows.NextResultSet() {
+	}
+	if err := rows.Err(); err == nil || !strings.Contains(err.Error(), "1 arguments left unused") {
+		t.Errorf("Expected unused arguments error, got %v", err)
+	}
+
+	_, err = db.Query("SELECT 1; -- trailing comment", 5)
+	if err == nil || !strings.Contains(err.Error(), "1 arguments left unused") {
+		t.Errorf("Expected unused arguments error, got %v", err)
+	}
+}
+
+func TestNextResultSetCachedStatement(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// Advancing past the last statement closes the rows, and database/sql
+	// closes them again. The cached statement must survive both.
+	for i := 0; i < 3; i++ {
+		rows, err := db.Query("SELECT 1; -- trailing comment")
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		for {
+			for rows.Next() {
+			}
+			if !rows.NextResultSet() {
+				break
+			}
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Failed to iterate result sets: %v", err)
+		}
+		rows.Close()
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected 1, got %d (%v)", n, err)
+	}
+}
+
+func TestVacuumAndAnalyze(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE INDEX "items by name" ON items (name);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO items (name) SELECT 'item' || i FROM n;
+		DELETE FROM items WHERE id > 100`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	snapshot := filepath.Join(dir, "snapshot.db")
+	err = conn.Raw(func(driverConn any) error {
+		c := driverConn.(*Conn)
+		if err := c.Vacuum(); err != nil {
+			return err
+		}
+		if err := c.Analyze("items by name"); err != nil {
+			return err
+		}
+		if err := c.Analyze(""); err != nil {
+			return err
+		}
+		return c.VacuumInto(snapshot)
+	})
+	if err != nil {
+		t.Fatalf("Failed to vacuum and analyze: %v", err)
+	}
+
+	var stats int
+	if err := conn.QueryRowContext(context.Background(), "SELECT count(*) FROM sqlite_stat1 WHERE idx = 'items by name'").Scan(&stats); err != nil {
+		t.Fatalf("Failed to read statistics: %v", err)
+	}
+	if stats != 1 {
+		t.Errorf("Expected statistics for the analyzed index, got %d rows", stats)
+	}
+
+	copied, err := sql.Open("sqlite3", snapshot)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer copied.Close()
+
+	var count int
+	var integrity string
+	if err := copied.QueryRow("SELECT (SELECT count(*) FROM items), (SELECT * FROM pragma_integrity_check)").Scan(&count, &integrity); err != nil {
+		t.Fatalf("Failed to query snapshot: %v", err)
+	}
+	if count != 100 {
+		t.Errorf("Expected 100 rows in snapshot, got %d", count)
+	}
+	if integrity != "ok" {
+		t.Errorf("Expected snapshot integrity ok, got %s", integrity)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).VacuumInto(snapshot)
+	})
+	if err == nil {
+		t.Error("Expected error vacuuming into an existing file")
+	}
+}
+
+func TestKillStatement(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	var c *Conn
+	conn.Raw(func(driverConn any) error {
+		c = driverConn.(*Conn)
+		return nil
+	})
+
+	// Open rows stay active until they are exhausted or closed.
+	rows, err := conn.QueryContext(context.Background(), "SELECT 1 UNION ALL SELECT 2")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	rows.Next()
+	if active := c.ActiveStatements(); len(active) != 1 || active[0].SQL != "SELECT 1 UNION ALL SELECT 2" {
+		t.Errorf("Expected the open query to be active, got %+v", active)
+	}
+	rows.Close()
+	if active := c.ActiveStatements(); len(active) != 0 {
+		t.Errorf("Expected no active statements after closing rows, got %+v", active)
+	}
+
+	const long = "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n"
+	killed := make(chan error, 1)
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if active := c.ActiveStatements(); len(active) == 1 && active[0].SQL == long {
+				killed <- c.KillStatement(active[0].ID)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		killed <- errors.New("long query never became active")
+	}()
+
+	var n int
+	err = conn.QueryRowContext(context.Background(), long).Scan(&n)
+	if kerr := <-killed; kerr != nil {
+		t.Fatalf("Failed to kill statement: %v", kerr)
+	}
+	if !errors.Is(err, ErrStatementKilled) {
+		t.Errorf("Expected ErrStatementKilled, got %v", err)
+	}
+	if active := c.ActiveStatements(); len(active) != 0 {
+		t.Errorf("Expected no active statements after the kill, got %+v", active)
+	}
+
+	// The connection keeps working, and the kill does not leak into later
+	// statements.
+	if err := conn.QueryRowContext(context.Background(), "SELECT 42").Scan(&n); err != nil || n != 42 {
+		t.Errorf("Expected 42 after the kill, got %d, %v", n, err)
+	}
+	if err := c.KillStatement(12345); err == nil {
+		t.Error("Expected error killing an unknown statement")
+	}
+
+	// A kill that arrives as its statement finishes is not blamed on the
+	// next error.
+	c.killed.Store(true)
+	_, err = conn.PrepareContext(context.Background(), "INSERT INTO nope VALUES (1)")
+	if err == nil || errors.Is(err, ErrStatementKilled) {
+		t.Errorf("Expected a plain error after a late kill, got %v", err)
+	}
+}
+
+func TestTimePrecision(t *testing.T) {
+	at := time.Date(2024, 3, 15, 14, 30, 45, 123456789, time.UTC)
+
+	tests := []struct {
+		precision string
+		expected  string
+	}{
+		{"second", "2024-03-15T14:30:45Z"},
+		{"milli", "2024-03-15T14:30:45.123Z"},
+		{"micro", "2024-03-15T14:30:45.123456Z"},
+		{"nano", "2024-03-15T14:30:45.123456789Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", "file::memory:?_time_precision="+tt.precision)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			if _, err := db.Exec("CREATE TABLE events (at DATETIME)"); err != nil {
+				t.Fatalf("Failed to create table: %v", err)
+			}
+			if _, err := db.Exec("INSERT INTO events VALUES (?)", at); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+
+			var stored string
+			if err := db.QueryRow("SELECT CAST(at AS TEXT) FROM events").Scan(&stored); err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			if stored != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, stored)
+			}
+
+			// Equality lookups match whenever both sides share the precision.
+			var count int
+			if err := db.QueryRow("SELECT count(*) FROM events WHERE at = ?", at).Scan(&count); err != nil {
+				t.Fatalf("Failed to query by time: %v", err)
+			}
+			if count != 1 {
+				t.Errorf("Expected equality lookup to match, got %d rows", count)
+			}
+		})
+	}
+
+	db, err := sql.Open("sqlite3", "file::memory:?_time_precision=second&_time_format=sqlite")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var stored string
+	if err := db.QueryRow("SELECT CAST(? AS TEXT)", at).Scan(&stored); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if stored != "2024-03-15 14:30:45" {
+		t.Errorf("Expected no fractional seconds, got %s", stored)
+	}
+
+	if _, err := parseDSN("file:test.db?_time_precision=minute"); err == nil {
+		t.Error("Expected error for unknown _time_precision")
+	}
+}
@@ -1,11 +1,19 @@
 package sqlite
 
 import (
+	"context"
 	"fmt"
 )
 
+// errorString describes a result code. Extended codes get a more specific
+// description where one is known and otherwise fall back to their primary
+// code's.
 func errorString(code int) string {
-	switch code {
+	if msg := extendedErrorDescription(code); msg != "" {
+		return msg
+	}
+
+	switch code & 0xff {
 	case SQLITE_OK:
 		return "not an error"
 	case SQLITE_ERROR:
@@ -65,11 +73,382 @@ func errorString(code int) string {
 	}
 }
 
+func extendedErrorDescription(code int) string {
+	switch code {
+	case SQLITE_ERROR_MISSING_COLLSEQ:
+		return "SQL logic error: missing collating sequence"
+	case SQLITE_ERROR_SNAPSHOT:
+		return "SQL logic error: snapshot no longer available"
+	case SQLITE_IOERR_READ:
+		return "disk I/O error: read failed"
+	case SQLITE_IOERR_SHORT_READ:
+		return "disk I/O error: short read"
+	case SQLITE_IOERR_WRITE:
+		return "disk I/O error: write failed"
+	case SQLITE_IOERR_FSYNC:
+		return "disk I/O error: fsync failed"
+	case SQLITE_IOERR_DIR_FSYNC:
+		return "disk I/O error: directory fsync failed"
+	case SQLITE_IOERR_TRUNCATE:
+		return "disk I/O error: truncate failed"
+	case SQLITE_IOERR_FSTAT:
+		return "disk I/O error: fstat failed"
+	case SQLITE_IOERR_UNLOCK:
+		return "disk I/O error: unlock failed"
+	case SQLITE_IOERR_RDLOCK:
+		return "disk I/O error: read lock failed"
+	case SQLITE_IOERR_DELETE:
+		return "disk I/O error: delete failed"
+	case SQLITE_IOERR_NOMEM:
+		return "disk I/O error: out of memory"
+	case SQLITE_IOERR_ACCESS:
+		return "disk I/O error: access check failed"
+	case SQLITE_IOERR_LOCK:
+		return "disk I/O error: lock failed"
+	case SQLITE_IOERR_CLOSE:
+		return "disk I/O error: close failed"
+	case SQLITE_IOERR_SHMOPEN:
+		return "disk I/O error: shared memory open failed"
+	case SQLITE_IOERR_SHMSIZE:
+		return "disk I/O error: shared memory resize failed"
+	case SQLITE_IOERR_SHMMAP:
+		return "disk I/O error: shared memory map failed"
+	case SQLITE_IOERR_SEEK:
+		return "disk I/O error: seek failed"
+	case SQLITE_IOERR_DELETE_NOENT:
+		return "disk I/O error: file to delete does not exist"
+	case SQLITE_IOERR_MMAP:
+		return "disk I/O error: memory map failed"
+	case SQLITE_IOERR_CORRUPTFS:
+		return "disk I/O error: filesystem corruption detected"
+	case SQLITE_LOCKED_SHAREDCACHE:
+		return "database table is locked: shared cache conflict"
+	case SQLITE_BUSY_RECOVERY:
+		return "database is locked: WAL recovery in progress"
+	case SQLITE_BUSY_SNAPSHOT:
+		return "database is locked: snapshot is stale"
+	case SQLITE_BUSY_TIMEOUT:
+		return "database is locked: timed out"
+	case SQLITE_CANTOPEN_NOTEMPDIR:
+		return "unable to open database file: no temporary directory"
+	case SQLITE_CANTOPEN_ISDIR:
+		return "unable to open database file: path is a directory"
+	case SQLITE_CANTOPEN_FULLPATH:
+		return "unable to open database file: cannot resolve full path"
+	case SQLITE_CANTOPEN_SYMLINK:
+		return "unable to open database file: path is a symbolic link"
+	case SQLITE_CORRUPT_INDEX:
+		return "database disk image is malformed: corrupt index"
+	case SQLITE_READONLY_RECOVERY:
+		return "attempt to write a readonly database: WAL recovery required"
+	case SQLITE_READONLY_CANTLOCK:
+		return "attempt to write a readonly database: cannot lock shared memory"
+	case SQLITE_READONLY_ROLLBACK:
+		return "attempt to write a readonly database: hot journal needs rollback"
+	case SQLITE_READONLY_DBMOVED:
+		return "attempt to write a readonly database: database file was moved"
+	case SQLITE_READONLY_DIRECTORY:
+		return "attempt to write a readonly database: directory is readonly"
+	case SQLITE_ABORT_ROLLBACK:
+		return "query aborted: transaction rolled back"
+	case SQLITE_CONSTRAINT_CHECK:
+		return "CHECK constraint failed"
+	case SQLITE_CONSTRAINT_FOREIGNKEY:
+		return "FOREIGN KEY constraint failed"
+	case SQLITE_CONSTRAINT_NOTNULL:
+		return "NOT NULL constraint failed"
+	case SQLITE_CONSTRAINT_PRIMARYKEY:
+		return "PRIMARY KEY constraint failed"
+	case SQLITE_CONSTRAINT_UNIQUE:
+		return "UNIQUE constraint failed"
+	case SQLITE_CONSTRAINT_TRIGGER:
+		return "constraint failed: raised by trigger"
+	case SQLITE_CONSTRAINT_DATATYPE:
+		return "constraint failed: datatype mismatch in STRICT table"
+	default:
+		return ""
+	}
+}
+
 func getErrorMessage(db uintptr) string {
 	msgPtr := sqlite3_errmsg(db)
 	if msgPtr == 0 {
-		code := sqlite3_errcode(db)
+		code := sqlite3_extended_errcode(db)
 		return errorString(code)
 	}
 	return goString(msgPtr)
 }
+
+// Error is returned when SQLite reports a failure. Code holds the primary
+// result code, ExtendedCode the extended one, which tells apart e.g. a
+// UNIQUE violation from a FOREIGN KEY violation.
+type Error struct {
+	Code         int
+	ExtendedCode int
+	Message      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, extendedErrorString(e.ExtendedCode))
+}
+
+func newError(db uintptr) *Error {
+	code := sqlite3_extended_errcode(db)
+	return &Error{
+		Code:         code & 0xff,
+		ExtendedCode: code,
+		Message:      getErrorMessage(db),
+	}
+}
+
+// SetErrorTranslator registers fn to convert every *Error the connection
+// reports before it is returned, for example to map constraint violations to
+// application error types. The driver still wraps the result with context,
+// so use errors.As or errors.Is to inspect it. Returning nil keeps the
+// original error. Errors that are not *Error, such as driver.ErrBadConn, are
+// never passed to fn. Passing nil removes the translator.
+func (c *Conn) SetErrorTranslator(fn func(*Error) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.translateError = fn
+}
+
+// lastError returns the connection's most recent SQLite error, translated.
+func (c *Conn) lastError() error {
+	sqliteErr := newError(c.db)
+	err := c.translate(sqliteErr)
+	if c.budgetExceeded.Swap(false) {
+		return fmt.Errorf("%w: %w", ErrMemoryBudgetExceeded, err)
+	}
+	if c.opLimitHit.Swap(false) {
+		return fmt.Errorf("%w: %w", ErrStatementOpLimit, err)
+	}
+	// A kill that raced a finishing statement must not be blamed on an
+	// unrelated error.
+	if c.killed.Swap(false) && sqliteErr.Code == SQLITE_INTERRUPT {
+		return fmt.Errorf("%w: %w", ErrStatementKilled, err)
+	}
+	if c.timedOut.Swap(false) {
+		return fmt.Errorf("query timeout of %s: %w: %w", c.cfg.queryTimeout, context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+func (c *Conn) translate(err *Error) error {
+	if c.translateError != nil {
+		if translated := c.translateError(err); translated != nil {
+			return translated
+		}
+	}
+	return err
+}
+
+func extendedErrorString(code int) string {
+	switch code {
+	case SQLITE_OK:
+		return "SQLITE_OK"
+	case SQLITE_ERROR:
+		return "SQLITE_ERROR"
+	case SQLITE_INTERNAL:
+		return "SQLITE_INTERNAL"
+	case SQLITE_PERM:
+		return "SQLITE_PERM"
+	case SQLITE_ABORT:
+		return "SQLITE_ABORT"
+	case SQLITE_BUSY:
+		return "SQLITE_BUSY"
+	case SQLITE_LOCKED:
+		return "SQLITE_LOCKED"
+	case SQLITE_NOMEM:
+		return "SQLITE_NOMEM"
+	case SQLITE_READONLY:
+		return "SQLITE_READONLY"
+	case SQLITE_INTERRUPT:
+		return "SQLITE_INTERRUPT"
+	case SQLITE_IOERR:
+		return "SQLITE_IOERR"
+	case SQLITE_CORRUPT:
+		return "SQLITE_CORRUPT"
+	case SQLITE_NOTFOUND:
+		return "SQLITE_NOTFOUND"
+	case SQLITE_FULL:
+		return "SQLITE_FULL"
+	case SQLITE_CANTOPEN:
+		return "SQLITE_CANTOPEN"
+	case SQLITE_PROTOCOL:
+		return "SQLITE_PROTOCOL"
+	case SQLITE_EMPTY:
+		return "SQLITE_EMPTY"
+	case SQLITE_SCHEMA:
+		return "SQLITE_SCHEMA"
+	case SQLITE_TOOBIG:
+		return "SQLITE_TOOBIG"
+	case SQLITE_CONSTRAINT:
+		return "SQLITE_CONSTRAINT"
+	case SQLITE_MISMATCH:
+		return "SQLITE_MISMATCH"
+	case SQLITE_MISUSE:
+		return "SQLITE_MISUSE"
+	case SQLITE_NOLFS:
+		return "SQLITE_NOLFS"
+	case SQLITE_AUTH:
+		return "SQLITE_AUTH"
+	case SQLITE_FORMAT:
+		return "SQLITE_FORMAT"
+	case SQLITE_RANGE:
+		return "SQLITE_RANGE"
+	case SQLITE_NOTADB:
+		return "SQLITE_NOTADB"
+	case SQLITE_NOTICE:
+		return "SQLITE_NOTICE"
+	case SQLITE_WARNING:
+		return "SQLITE_WARNING"
+	case SQLITE_ERROR_MISSING_COLLSEQ:
+		return "SQLITE_ERROR_MISSING_COLLSEQ"
+	case SQLITE_ERROR_RETRY:
+		return "SQLITE_ERROR_RETRY"
+	case SQLITE_ERROR_SNAPSHOT:
+		return "SQLITE_ERROR_SNAPSHOT"
+	case SQLITE_IOERR_READ:
+		return "SQLITE_IOERR_READ"
+	case SQLITE_IOERR_SHORT_READ:
+		return "SQLITE_IOERR_SHORT_READ"
+	case SQLITE_IOERR_WRITE:
+		return "SQLITE_IOERR_WRITE"
+	case SQLITE_IOERR_FSYNC:
+		return "SQLITE_IOERR_FSYNC"
+	case SQLITE_IOERR_DIR_FSYNC:
+		return "SQLITE_IOERR_DIR_FSYNC"
+	case SQLITE_IOERR_TRUNCATE:
+		return "SQLITE_IOERR_TRUNCATE"
+	case SQLITE_IOERR_FSTAT:
+		return "SQLITE_IOERR_FSTAT"
+	case SQLITE_IOERR_UNLOCK:
+		return "SQLITE_IOERR_UNLOCK"
+	case SQLITE_IOERR_RDLOCK:
+		return "SQLITE_IOERR_RDLOCK"
+	case SQLITE_IOERR_DELETE:
+		return "SQLITE_IOERR_DELETE"
+	case SQLITE_IOERR_BLOCKED:
+		return "SQLITE_IOERR_BLOCKED"
+	case SQLITE_IOERR_NOMEM:
+		return "SQLITE_IOERR_NOMEM"
+	case SQLITE_IOERR_ACCESS:
+		return "SQLITE_IOERR_ACCESS"
+	case SQLITE_IOERR_CHECKRESERVEDLOCK:
+		return "SQLITE_IOERR_CHECKRESERVEDLOCK"
+	case SQLITE_IOERR_LOCK:
+		return "SQLITE_IOERR_LOCK"
+	case SQLITE_IOERR_CLOSE:
+		return "SQLITE_IOERR_CLOSE"
+	case SQLITE_IOERR_DIR_CLOSE:
+		return "SQLITE_IOERR_DIR_CLOSE"
+	case SQLITE_IOERR_SHMOPEN:
+		return "SQLITE_IOERR_SHMOPEN"
+	case SQLITE_IOERR_SHMSIZE:
+		return "SQLITE_IOERR_SHMSIZE"
+	case SQLITE_IOERR_SHMLOCK:
+		return "SQLITE_IOERR_SHMLOCK"
+	case SQLITE_IOERR_SHMMAP:
+		return "SQLITE_IOERR_SHMMAP"
+	case SQLITE_IOERR_SEEK:
+		return "SQLITE_IOERR_SEEK"
+	case SQLITE_IOERR_DELETE_NOENT:
+		return "SQLITE_IOERR_DELETE_NOENT"
+	case SQLITE_IOERR_MMAP:
+		return "SQLITE_IOERR_MMAP"
+	case SQLITE_IOERR_GETTEMPPATH:
+		return "SQLITE_IOERR_GETTEMPPATH"
+	case SQLITE_IOERR_CONVPATH:
+		return "SQLITE_IOERR_CONVPATH"
+	case SQLITE_IOERR_VNODE:
+		return "SQLITE_IOERR_VNODE"
+	case SQLITE_IOERR_AUTH:
+		return "SQLITE_IOERR_AUTH"
+	case SQLITE_IOERR_BEGIN_ATOMIC:
+		return "SQLITE_IOERR_BEGIN_ATOMIC"
+	case SQLITE_IOERR_COMMIT_ATOMIC:
+		return "SQLITE_IOERR_COMMIT_ATOMIC"
+	case SQLITE_IOERR_ROLLBACK_ATOMIC:
+		return "SQLITE_IOERR_ROLLBACK_ATOMIC"
+	case SQLITE_IOERR_DATA:
+		return "SQLITE_IOERR_DATA"
+	case SQLITE_IOERR_CORRUPTFS:
+		return "SQLITE_IOERR_CORRUPTFS"
+	case SQLITE_LOCKED_SHAREDCACHE:
+		return "SQLITE_LOCKED_SHAREDCACHE"
+	case SQLITE_LOCKED_VTAB:
+		return "SQLITE_LOCKED_VTAB"
+	case SQLITE_BUSY_RECOVERY:
+		return "SQLITE_BUSY_RECOVERY"
+	case SQLITE_BUSY_SNAPSHOT:
+		return "SQLITE_BUSY_SNAPSHOT"
+	case SQLITE_BUSY_TIMEOUT:
+		return "SQLITE_BUSY_TIMEOUT"
+	case SQLITE_CANTOPEN_NOTEMPDIR:
+		return "SQLITE_CANTOPEN_NOTEMPDIR"
+	case SQLITE_CANTOPEN_ISDIR:
+		return "SQLITE_CANTOPEN_ISDIR"
+	case SQLITE_CANTOPEN_FULLPATH:
+		return "SQLITE_CANTOPEN_FULLPATH"
+	case SQLITE_CANTOPEN_CONVPATH:
+		return "SQLITE_CANTOPEN_CONVPATH"
+	case SQLITE_CANTOPEN_SYMLINK:
+		return "SQLITE_CANTOPEN_SYMLINK"
+	case SQLITE_CORRUPT_VTAB:
+		return "SQLITE_CORRUPT_VTAB"
+	case SQLITE_CORRUPT_SEQUENCE:
+		return "SQLITE_CORRUPT_SEQUENCE"
+	case SQLITE_CORRUPT_INDEX:
+		return "SQLITE_CORRUPT_INDEX"
+	case SQLITE_READONLY_RECOVERY:
+		return "SQLITE_READONLY_RECOVERY"
+	case SQLITE_READONLY_CANTLOCK:
+		return "SQLITE_READONLY_CANTLOCK"
+	case SQLITE_READONLY_ROLLBACK:
+		return "SQLITE_READONLY_ROLLBACK"
+	case SQLITE_READONLY_DBMOVED:
+		return "SQLITE_READONLY_DBMOVED"
+	case SQLITE_READONLY_CANTINIT:
+		return "SQLITE_READONLY_CANTINIT"
+	case SQLITE_READONLY_DIRECTORY:
+		return "SQLITE_READONLY_DIRECTORY"
+	case SQLITE_ABORT_ROLLBACK:
+		return "SQLITE_ABORT_ROLLBACK"
+	case SQLITE_CONSTRAINT_CHECK:
+		return "SQLITE_CONSTRAINT_CHECK"
+	case SQLITE_CONSTRAINT_COMMITHOOK:
+		return "SQLITE_CONSTRAINT_COMMITHOOK"
+	case SQLITE_CONSTRAINT_FOREIGNKEY:
+		return "SQLITE_CONSTRAINT_FOREIGNKEY"
+	case SQLITE_CONSTRAINT_FUNCTION:
+		return "SQLITE_CONSTRAINT_FUNCTION"
+	case SQLITE_CONSTRAINT_NOTNULL:
+		return "SQLITE_CONSTRAINT_NOTNULL"
+	case SQLITE_CONSTRAINT_PRIMARYKEY:
+		return "SQLITE_CONSTRAINT_PRIMARYKEY"
+	case SQLITE_CONSTRAINT_TRIGGER:
+		return "SQLITE_CONSTRAINT_TRIGGER"
+	case SQLITE_CONSTRAINT_UNIQUE:
+		return "SQLITE_CONSTRAINT_UNIQUE"
+	case SQLITE_CONSTRAINT_VTAB:
+		return "SQLITE_CONSTRAINT_VTAB"
+	case SQLITE_CONSTRAINT_ROWID:
+		return "SQLITE_CONSTRAINT_ROWID"
+	case SQLITE_CONSTRAINT_PINNED:
+		return "SQLITE_CONSTRAINT_PINNED"
+	case SQLITE_CONSTRAINT_DATATYPE:
+		return "SQLITE_CONSTRAINT_DATATYPE"
+	case SQLITE_NOTICE_RECOVER_WAL:
+		return "SQLITE_NOTICE_RECOVER_WAL"
+	case SQLITE_NOTICE_RECOVER_ROLLBACK:
+		return "SQLITE_NOTICE_RECOVER_ROLLBACK"
+	case SQLITE_WARNING_AUTOINDEX:
+		return "SQLITE_WARNING_AUTOINDEX"
+	case SQLITE_AUTH_USER:
+		return "SQLITE_AUTH_USER"
+	default:
+		return fmt.Sprintf("SQLITE_UNKNOWN(%d)", code)
+	}
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// EnableLoadExtension allows or forbids LoadExtension on this connection.
+// Extension loading is disabled by default. Enabling it also makes the
+// load_extension() SQL function available, so disable it again once the
+// extensions are loaded.
+func (c *Conn) EnableLoadExtension(enabled bool) error {
+	if sqlite3_enable_load_extension == nil {
+		return errors.New("enable load extension failed: SQLite built without extension loading")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	onoff := 0
+	if enabled {
+		onoff = 1
+	}
+
+	if rc := sqlite3_enable_load_extension(c.db, onoff); rc != SQLITE_OK {
+		return fmt.Errorf("enable load extension failed: %w", c.lastError())
+	}
+	return nil
+}
+
+// LoadExtension loads the shared library at path into this connection. An
+// empty entryPoint lets SQLite derive the entry point from the file name.
+// EnableLoadExtension must be called first. The extension must be built
+// against an ABI-compatible SQLite, since it runs inside the loaded library.
+func (c *Conn) LoadExtension(path, entryPoint string) error {
+	if sqlite3_load_extension == nil {
+		return errors.New("load extension failed: SQLite built without extension loading")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	pathPtr, pathPinner := cString(path)
+	defer unpin(pathPinner)
+
+	entryPtr, entryPinner := cString(entryPoint)
+	defer unpin(entryPinner)
+
+	var errMsg uintptr
+	rc := sqlite3_load_extension(c.db, pathPtr, entryPtr, &errMsg)
+	if rc != SQLITE_OK {
+		msg := goString(errMsg)
+		sqlite3_free(errMsg)
+		return fmt.Errorf("load extension failed: %w", c.translate(&Error{
+			Code:         rc & 0xff,
+			ExtendedCode: rc,
+			Message:      msg,
+		}))
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package sqlite
+
+import "syscall"
+
+// Filesystem magic numbers from statfs(2) for network and cluster
+// filesystems, where WAL's shared memory cannot work.
+var networkFilesystemTypes = map[int64]bool{
+	0x6969:     true, // NFS
+	0x517b:     true, // SMB
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+	0x5346414f: true, // AFS
+	0x00c36400: true, // Ceph
+	0x73757245: true, // Coda
+	0x01021997: true, // 9P
+	0x0bd00bd0: true, // Lustre
+	0x01161970: true, // GFS2
+	0x7461636f: true, // OCFS2
+}
+
+func isNetworkFilesystem(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return networkFilesystemTypes[int64(st.Type)], nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package sqlite
+
+// isNetworkFilesystem cannot tell on this platform and assumes a local one.
+func isNetworkFilesystem(path string) (bool, error) {
+	return false, nil
+}
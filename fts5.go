@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// Tokenizer splits text into tokens for an FTS5 table. Tokenize calls emit
+// for every token with the byte offsets of the text it was read from, which
+// FTS5 uses for highlight() and snippet(). flags is one of the
+// FTS5_TOKENIZE_* constants and tells whether text is a document, a query,
+// a prefix query term or the argument of an auxiliary function. An error
+// returned by emit must be returned by Tokenize.
+type Tokenizer interface {
+	Tokenize(text string, flags int, emit func(token string, start, end int) error) error
+}
+
+// TokenizerFactory creates a Tokenizer for an FTS5 table. args are the
+// words following the tokenizer's name in the table's tokenize option.
+type TokenizerFactory func(args []string) (Tokenizer, error)
+
+// fts5API mirrors the start of FTS5's fts5_api struct.
+type fts5API struct {
+	version         int32
+	createTokenizer uintptr
+}
+
+// fts5TokenizerMethods is the fts5_tokenizer struct passed to FTS5, which
+// copies it.
+var fts5TokenizerMethods struct {
+	create   uintptr
+	delete   uintptr
+	tokenize uintptr
+}
+
+// RegisterFTS5Tokenizer registers a tokenizer called name for FTS5 tables
+// created or opened on this connection, as in
+// CREATE VIRTUAL TABLE t USING fts5(body, tokenize = 'name arg ...').
+// Registering an existing name replaces it for tables opened afterwards. It
+// fails if the SQLite library was built without FTS5.
+func (c *Conn) RegisterFTS5Tokenizer(name string, factory TokenizerFactory) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	api, err := c.fts5API()
+	if err != nil {
+		return err
+	}
+
+	initCallbacks()
+
+	handle := nextTokenizerHandle.Add(1)
+	tokenizerFactories.Store(handle, factory)
+
+	namePtr, pinner := cString(name)
+	defer unpin(pinner)
+
+	createTokenizer := (*fts5API)(cPointer(api)).createTokenizer
+	rc, _, _ := purego.SyscallN(createTokenizer, api, namePtr, handle,
+		uintptr(unsafe.Pointer(&fts5TokenizerMethods)), tokenizerDestroyCallback)
+	if int(rc) != SQLITE_OK {
+		tokenizerFactories.Delete(handle)
+		return fmt.Errorf("create tokenizer failed: %s", errorString(int(rc)))
+	}
+
+	return nil
+}
+
+// fts5API returns the connection's fts5_api pointer, which FTS5 hands out
+// through its fts5() SQL function. The caller must hold c.mu.
+func (c *Conn) fts5API() (uintptr, error) {
+	if sqlite3_bind_pointer == nil {
+		return 0, errors.New("FTS5 tokenizers need SQLite 3.20 or later")
+	}
+
+	queryPtr, queryPinner := cString("SELECT fts5(?1)")
+	defer unpin(queryPinner)
+
+	var stmt uintptr
+	if sqlite3_prepare_v2(c.db, queryPtr, -1, &stmt, nil) != SQLITE_OK {
+		return 0, fmt.Errorf("FTS5 not available: %w", c.lastError())
+	}
+	defer sqlite3_finalize(stmt)
+
+	// FTS5 writes the pointer into api, so it must stay put until then.
+	api := new(uintptr)
+	var pinner runtime.Pinner
+	pinner.Pin(api)
+	defer pinner.Unpin()
+
+	typePtr, typePinner := cString("fts5_api_ptr")
+	defer unpin(typePinner)
+
+	sqlite3_bind_pointer(stmt, 1, unsafe.Pointer(api), typePtr, 0)
+	defer c.endQuery()
+	if c.stepFirst(stmt) != SQLITE_ROW || *api == 0 {
+		return 0, errors.New("FTS5 not available")
+	}
+
+	return *api, nil
+}
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Function implements an SQL function registered with RegisterFunction. It
+// returns nil, an integer, float, bool, string, []byte, time.Time or
+// driver.Valuer, or one of its arguments to pass that argument through
+// unchanged. A non-nil error fails the statement with its message.
+type Function func(args []FuncArg) (any, error)
+
+// FuncArg is an argument of a Function call. It is only valid until the
+// function returns.
+type FuncArg struct {
+	value uintptr
+}
+
+// Type returns the argument's storage class, one of SQLITE_INTEGER,
+// SQLITE_REAL, SQLITE_TEXT, SQLITE_BLOB or SQLITE_NULL.
+func (a FuncArg) Type() int {
+	return sqlite3_value_type(a.value)
+}
+
+// Value copies the argument into an int64, float64, string, []byte or nil.
+func (a FuncArg) Value() driver.Value {
+	return goValue(a.value)
+}
+
+// RegisterFunction registers fn as a scalar SQL function called name taking
+// nArg arguments, or any number when nArg is -1. Deterministic functions
+// always return the same result for the same arguments, which lets SQLite
+// use them in indexes and factor them out of loops. Registering an existing
+// name and argument count replaces it.
+//
+// fn runs while the statement calling it holds the connection and must not
+// use the connection itself. Returning an argument hands SQLite its original
+// value, keeping its exact type and subtype, such as the JSON subtype set by
+// json(), which a round trip through Go would lose.
+func (c *Conn) RegisterFunction(name string, nArg int, deterministic bool, fn Function) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	initCallbacks()
+
+	handle := nextFunctionHandle.Add(1)
+	functionHandles.Store(handle, fn)
+
+	namePtr, pinner := cString(name)
+	defer unpin(pinner)
+
+	flags := SQLITE_UTF8 | SQLITE_SUBTYPE | SQLITE_RESULT_SUBTYPE
+	if deterministic {
+		flags |= SQLITE_DETERMINISTIC
+	}
+
+	rc := sqlite3_create_function_v2(c.db, namePtr, nArg, flags, handle, functionCallback, 0, 0, functionDestroyCallback)
+	if rc != SQLITE_OK {
+		// As with collations, SQLite calls the destroy callback only on
+		// success.
+		functionHandles.Delete(handle)
+		return fmt.Errorf("create function failed: %w", c.lastError())
+	}
+
+	return nil
+}
+
+// setResult reports a Function's return value to SQLite.
+func setResult(ctx uintptr, result any) error {
+	if valuer, ok := result.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		result = value
+	}
+
+	switch v := result.(type) {
+	case nil:
+		sqlite3_result_null(ctx)
+	case FuncArg:
+		sqlite3_result_value(ctx, v.value)
+	case int64:
+		sqlite3_result_int64(ctx, v)
+	case int:
+		sqlite3_result_int64(ctx, int64(v))
+	case int32:
+		sqlite3_result_int64(ctx, int64(v))
+	case bool:
+		if v {
+			sqlite3_result_int64(ctx, 1)
+		} else {
+			sqlite3_result_int64(ctx, 0)
+		}
+	case float64:
+		sqlite3_result_double(ctx, v)
+	case float32:
+		sqlite3_result_double(ctx, float64(v))
+	case string:
+		ptr, pinner := pinString(v)
+		defer unpin(pinner)
+		sqlite3_result_text(ctx, ptr, len(v), SQLITE_TRANSIENT)
+	case []byte:
+		if v == nil {
+			sqlite3_result_null(ctx)
+			return nil
+		}
+		if len(v) == 0 {
+			sqlite3_result_zeroblob(ctx, 0)
+			return nil
+		}
+		ptr, pinner := allocateBytes(v)
+		defer unpin(pinner)
+		sqlite3_result_blob(ctx, ptr, len(v), SQLITE_TRANSIENT)
+	case time.Time:
+		return setResult(ctx, v.Format(time.RFC3339Nano))
+	default:
+		return fmt.Errorf("unsupported function result type %T", result)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Vacuum rebuilds the main database, repacking it into the minimum amount
+// of disk space. It fails inside a transaction.
+func (c *Conn) Vacuum() error {
+	if _, err := c.execDirect("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum failed: %w", err)
+	}
+	return nil
+}
+
+// VacuumInto writes a vacuumed copy of the main database to a new file at
+// path with VACUUM INTO. The copy is a consistent snapshot, and the original
+// database is left untouched and may be written to concurrently. The target
+// file must not already exist.
+func (c *Conn) VacuumInto(path string) error {
+	stmt, _, err := c.prepare("VACUUM INTO ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(context.Background(), []driver.NamedValue{{Ordinal: 1, Value: path}}); err != nil {
+		return fmt.Errorf("vacuum into failed: %w", err)
+	}
+
+	return nil
+}
+
+// Analyze gathers the statistics the query planner uses for target, which
+// names a schema, table or index. An empty target analyzes every attached
+// database.
+func (c *Conn) Analyze(target string) error {
+	query := "ANALYZE"
+	if target != "" {
+		query += " " + quoteIdentifier(target)
+	}
+
+	if _, err := c.execDirect(query); err != nil {
+		return fmt.Errorf("analyze failed: %w", err)
+	}
+	return nil
+}
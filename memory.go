@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"errors"
+)
+
+// ErrMemoryBudgetExceeded is wrapped by errors from statements that were
+// interrupted because the connection went over its memory budget.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// SetMemoryBudget interrupts any statement on this connection once the page
+// cache holds more than bytes, as reported by SQLITE_DBSTATUS_CACHE_USED.
+// The statement fails with an error wrapping ErrMemoryBudgetExceeded. The
+// check runs every few thousand instructions, so usage can briefly overshoot.
+// Memory outside the page cache, such as sorter buffers, is not counted; the
+// process-wide SoftHeapLimit covers that. A budget of 0 or less
+// removes the limit.
+func (c *Conn) SetMemoryBudget(bytes int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	c.memoryBudget = max(bytes, 0)
+	c.updateProgress()
+	return nil
+}
+
+// cacheUsed returns the bytes held by the connection's page caches.
+func (c *Conn) cacheUsed() int64 {
+	var current, highwater int32
+	if sqlite3_db_status(c.db, SQLITE_DBSTATUS_CACHE_USED, &current, &highwater, 0) != SQLITE_OK {
+		return 0
+	}
+	return int64(current)
+}
+
+// SoftHeapLimit sets the process-wide soft limit on the memory SQLite
+// allocates, in bytes, and returns the previous limit. SQLite releases cache
+// memory to stay under the limit but does not fail allocations that exceed
+// it. A limit of 0 removes it, and a negative n only reports the current
+// limit. It returns -1 if the SQLite library cannot be loaded.
+func SoftHeapLimit(n int64) int64 {
+	if err := openSQLite3(); err != nil {
+		return -1
+	}
+	return sqlite3_soft_heap_limit64(n)
+}
+
+// MemoryUsed returns the bytes SQLite currently has allocated across the
+// process and the highest value since the process started.
+func MemoryUsed() (current, high int64) {
+	if err := openSQLite3(); err != nil {
+		return 0, 0
+	}
+	return sqlite3_memory_used(), sqlite3_memory_highwater(0)
+}
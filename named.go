@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"time"
+)
+
+// StructArgs returns the fields of the struct v, or of the struct v points
+// to, as sql.Named arguments for use with :name, @name or $name parameters:
+//
+//	db.Exec("INSERT INTO users (name, age) VALUES (:name, :age)", sqlite.StructArgs(user)...)
+//
+// Only exported fields with a db tag are included, named after the tag; a tag
+// of "-" skips the field. Embedded structs contribute their fields as if they
+// were declared in v. Other struct fields are flattened with their tag and an
+// underscore as a prefix, so Address.City tagged db:"city" in a field tagged
+// db:"address" becomes :address_city, unless the struct is a time.Time or
+// implements driver.Valuer. Nil pointer fields bind NULL. Named arguments a
+// statement does not use are ignored. It returns nil if v is not a struct.
+func StructArgs(v any) []any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return appendStructArgs(nil, "", rv.Type(), rv)
+}
+
+var (
+	timeType   = reflect.TypeFor[time.Time]()
+	valuerType = reflect.TypeFor[driver.Valuer]()
+)
+
+// appendStructArgs appends the tagged fields of a struct of type t. v is the
+// zero Value when the struct sits behind a nil pointer, in which case every
+// field is NULL.
+func appendStructArgs(args []any, prefix string, t reflect.Type, v reflect.Value) []any {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, tagged := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		}
+
+		if field.Anonymous && !tagged {
+			if ft, fv, ok := nestedStruct(field.Type, fv); ok {
+				args = appendStructArgs(args, prefix, ft, fv)
+			}
+			continue
+		}
+		if !tagged || !field.IsExported() {
+			continue
+		}
+
+		name := prefix + tag
+		if ft, fv, ok := nestedStruct(field.Type, fv); ok {
+			args = appendStructArgs(args, name+"_", ft, fv)
+			continue
+		}
+
+		var value any
+		if fv.IsValid() {
+			value = fv.Interface()
+		}
+		args = append(args, sql.Named(name, value))
+	}
+
+	return args
+}
+
+// nestedStruct reports whether a field of type t is a struct, or a pointer to
+// one, whose fields should be flattened rather than bound as a single value.
+// It returns the struct type and value, following a non-nil pointer; the
+// value is the zero Value for a nil pointer.
+func nestedStruct(t reflect.Type, v reflect.Value) (reflect.Type, reflect.Value, bool) {
+	if t.Implements(valuerType) {
+		return nil, reflect.Value{}, false
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if v.IsValid() {
+			v = v.Elem()
+		}
+	}
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PointerTo(t).Implements(valuerType) {
+		return nil, reflect.Value{}, false
+	}
+
+	return t, v, true
+}
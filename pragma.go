@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	journalModes      = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	synchronousLevels = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// SetForeignKeys enables or disables foreign key enforcement. It has no effect
+// inside a transaction.
+func (c *Conn) SetForeignKeys(enabled bool) error {
+	value := "OFF"
+	if enabled {
+		value = "ON"
+	}
+	_, err := c.pragma("PRAGMA foreign_keys = " + value)
+	return err
+}
+
+// SetJournalMode changes the journal mode and returns the mode SQLite
+// actually applied, which may differ from the requested one (for example WAL
+// on in-memory databases or network filesystems).
+func (c *Conn) SetJournalMode(mode string) (string, error) {
+	mode, err := pragmaValue("journal mode", mode, journalModes)
+	if err != nil {
+		return "", err
+	}
+
+	applied, err := c.pragma("PRAGMA journal_mode = " + mode)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(applied), nil
+}
+
+// SetSynchronous sets the synchronous level to OFF, NORMAL, FULL or EXTRA.
+func (c *Conn) SetSynchronous(level string) error {
+	level, err := pragmaValue("synchronous level", level, synchronousLevels)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.pragma("PRAGMA synchronous = " + level)
+	return err
+}
+
+// SetBusyTimeout sets how long the connection waits for a locked database
+// before returning SQLITE_BUSY. A zero or negative duration disables waiting.
+func (c *Conn) SetBusyTimeout(d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	rc := sqlite3_busy_timeout(c.db, durationMillis(d))
+	if rc != SQLITE_OK {
+		return fmt.Errorf("busy timeout failed: %w", c.lastError())
+	}
+
+	return nil
+}
+
+// Pragmas reads several PRAGMA values in one batch and returns them keyed by
+// name. Names may carry a schema prefix such as "main.page_size". Pragmas
+// that return several rows report the first column of their first row.
+func (c *Conn) Pragmas(names ...string) (map[string]string, error) {
+	var query strings.Builder
+	for _, name := range names {
+		if !validPragmaName(name) {
+			return nil, fmt.Errorf("invalid pragma name: %s", name)
+		}
+		query.WriteString("PRAGMA " + name + ";")
+	}
+
+	values := make(map[string]string, len(names))
+	tail := query.String()
+	for _, name := range names {
+		stmt, rest, err := c.prepare(tail)
+		if err != nil {
+			return nil, err
+		}
+
+		// Each tail is a different SQL text, so keep them out of the
+		// statement cache rather than filling it with one-off entries.
+		value, err := c.firstValue(stmt)
+		stmt.finalize()
+		if err != nil {
+			return nil, err
+		}
+
+		values[name] = value
+		tail = rest
+	}
+
+	return values, nil
+}
+
+// pragma runs a PRAGMA statement and returns the first column of its first
+// row, or an empty string if it returns nothing.
+func (c *Conn) pragma(query string) (string, error) {
+	stmt, _, err := c.prepare(query)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	return c.firstValue(stmt)
+}
+
+func (c *Conn) firstValue(stmt *Stmt) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch rc := stmt.step(context.Background()); rc {
+	case SQLITE_ROW:
+		return goString(sqlite3_column_text(stmt.stmt, 0)), nil
+	case SQLITE_DONE:
+		return "", nil
+	default:
+		return "", fmt.Errorf("pragma failed: %w", c.lastError())
+	}
+}
+
+func validPragmaName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// pragmaValue validates value against the allowed PRAGMA keywords, since
+// PRAGMA arguments cannot be bound as parameters.
+func pragmaValue(name, value string, allowed []string) (string, error) {
+	upper := strings.ToUpper(value)
+	for _, v := range allowed {
+		if upper == v {
+			return upper, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s: %s", name, value)
+}
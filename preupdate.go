@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"errors"
+)
+
+// SetPreUpdateHook registers fn to run before each row is inserted, updated
+// or deleted in a rowid table. op is SQLITE_INSERT, SQLITE_UPDATE or
+// SQLITE_DELETE. old holds the row's column values before the change and is
+// nil for inserts; new holds them after and is nil for deletes. Passing nil
+// removes the hook.
+//
+// This requires a library built with SQLITE_ENABLE_PREUPDATE_HOOK. fn must not
+// call back into the Conn.
+func (c *Conn) SetPreUpdateHook(fn func(op int, db, table string, oldRowid, newRowid int64, old, new []driver.Value)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+	if sqlite3_preupdate_hook == nil {
+		return errors.New("pre-update hook not supported: SQLite built without SQLITE_ENABLE_PREUPDATE_HOOK")
+	}
+
+	c.preUpdate = fn
+	if fn == nil {
+		sqlite3_preupdate_hook(c.db, 0, 0)
+	} else {
+		initCallbacks()
+		sqlite3_preupdate_hook(c.db, preUpdateCallback, c.handle)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"errors"
+	"time"
+)
+
+// progressInterval is how many virtual machine instructions run between
+// progress handler calls.
+const progressInterval = 1000
+
+// ErrStatementOpLimit is wrapped by errors from statements that were
+// interrupted for running more instructions than SetStatementOpLimit allows.
+var ErrStatementOpLimit = errors.New("statement op limit exceeded")
+
+// SetStatementOpLimit interrupts any statement on this connection that runs
+// more than ops virtual machine instructions, whatever its context. The
+// statement fails with an error wrapping ErrStatementOpLimit. Instructions
+// are counted from the start of the statement until it finishes or is reset,
+// including those run while stepping through rows. An ops of 0 or less
+// removes the limit.
+func (c *Conn) SetStatementOpLimit(ops int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return
+	}
+
+	c.opLimit = int64(max(ops, 0))
+	c.updateProgress()
+}
+
+// progressNeeded reports whether any feature relies on the progress handler.
+func (c *Conn) progressNeeded() bool {
+	return c.memoryBudget > 0 || c.cfg.queryTimeout > 0 || c.opLimit > 0
+}
+
+// updateProgress installs the progress handler while any feature needs it.
+// A small op limit shortens the interval so it is enforced closely.
+func (c *Conn) updateProgress() {
+	if !c.progressNeeded() {
+		sqlite3_progress_handler(c.db, 0, 0, 0)
+		return
+	}
+
+	c.progressStep = progressInterval
+	if c.opLimit > 0 {
+		c.progressStep = min(c.progressStep, c.opLimit)
+	}
+
+	initCallbacks()
+	sqlite3_progress_handler(c.db, int(c.progressStep), progressCallback, c.handle)
+}
+
+// startQuery starts the _query_timeout clock and the op count for a
+// statement about to run.
+func (c *Conn) startQuery() {
+	if c.cfg.queryTimeout > 0 {
+		c.deadline.Store(time.Now().Add(c.cfg.queryTimeout).UnixNano())
+	}
+	c.opsUsed.Store(0)
+	c.killed.Store(false)
+}
+
+// endQuery stops the _query_timeout clock once a statement has finished or
+// been reset, so the next one does not run against its deadline.
+func (c *Conn) endQuery() {
+	c.deadline.Store(0)
+}
+
+// stepFirst takes the first step of stmt, starting its clock and op count.
+// Later steps call sqlite3_step directly, so the limits cover the whole
+// statement. The caller must hold c.mu and end the query when stmt is done.
+func (c *Conn) stepFirst(stmt uintptr) int {
+	c.startQuery()
+	return sqlite3_step(stmt)
+}
+
+// exec runs query with sqlite3_exec under the statement limits. The caller
+// must hold c.mu.
+func (c *Conn) exec(query string) int {
+	queryPtr, pinner := cString(query)
+	defer unpin(pinner)
+
+	c.startQuery()
+	defer c.endQuery()
+	return sqlite3_exec(c.db, queryPtr, 0, 0, 0)
+}
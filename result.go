@@ -2,6 +2,17 @@ package sqlite
 
 import "database/sql/driver"
 
+// Result reports the outcome of an Exec.
+//
+// LastInsertId returns sqlite3_last_insert_rowid: the rowid of the most
+// recent successful INSERT on the connection. A statement that inserts
+// nothing, such as an INSERT OR IGNORE that was skipped, leaves it at the
+// previous value, so check RowsAffected first. INSERT OR REPLACE reports
+// the rowid of the newly inserted row.
+//
+// RowsAffected returns sqlite3_changes64, or sqlite3_changes before SQLite
+// 3.37. Rows removed implicitly by REPLACE conflict resolution are not
+// counted, so a replacing insert reports 1.
 type Result struct {
 	lastInsertID int64
 	rowsAffected int64
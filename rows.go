@@ -1,21 +1,30 @@
 package sqlite
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"database/sql/driver"
-	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
 type Rows struct {
-	stmt    *Stmt
-	columns []string
-	ctx     context.Context
-	done    bool
+	stmt     *Stmt
+	columns  []string
+	ctx      context.Context
+	done     bool
+	ownsStmt bool
+	stepped  bool // Whether Next has stepped the statement
+	closed   bool
+
+	// The statements following stmt in a multi-statement query, and the
+	// arguments left for them.
+	tail string
+	args []driver.NamedValue
 }
 
 func (r *Rows) Columns() []string {
@@ -23,11 +32,28 @@ func (r *Rows) Columns() []string {
 }
 
 func (r *Rows) Close() error {
-	if !r.done {
-		sqlite3_reset(r.stmt.stmt)
-		r.done = true
+	if r.closed {
+		return nil
 	}
-	return nil
+	r.closed = true
+	r.done = true
+
+	r.stmt.conn.mu.Lock()
+	if r.stmt.closed {
+		r.stmt.conn.mu.Unlock()
+		return nil
+	}
+	r.stmt.reset()
+	r.stmt.conn.mu.Unlock()
+
+	if !r.ownsStmt {
+		return nil
+	}
+
+	// The statement may go back to the cache, where it is no longer ours.
+	stmt := r.stmt
+	r.stmt, r.ownsStmt = nil, false
+	return stmt.Close()
 }
 
 func (r *Rows) Next(dest []driver.Value) error {
@@ -41,15 +67,33 @@ func (r *Rows) Next(dest []driver.Value) error {
 		return io.EOF
 	}
 
-	rc := sqlite3_step(r.stmt.stmt)
+	r.stmt.conn.mu.Lock()
+	defer r.stmt.conn.mu.Unlock()
+
+	// Closing the connection finalizes its statements.
+	if r.stmt.closed {
+		return driver.ErrBadConn
+	}
+
+	var rc int
+	if r.stepped {
+		rc = sqlite3_step(r.stmt.stmt)
+	} else {
+		restore := r.stmt.conn.overrideBusyTimeout(r.ctx)
+		rc = r.stmt.step(r.ctx)
+		restore()
+		r.stepped = true
+	}
 
 	if rc == SQLITE_DONE {
 		r.done = true
+		r.stmt.untrack()
 		return io.EOF
 	}
 
 	if rc != SQLITE_ROW {
-		return fmt.Errorf("step failed: %s", getErrorMessage(r.stmt.conn.db))
+		r.stmt.untrack()
+		return fmt.Errorf("step failed: %w", r.stmt.stepError())
 	}
 
 	if len(dest) != len(r.columns) {
@@ -69,12 +113,14 @@ func (r *Rows) Next(dest []driver.Value) error {
 func (r *Rows) scanColumn(i int, colType int, declType string) driver.Value {
 	isTimeType := false
 	isBoolType := false
+	isJSONType := false
 	if declType != "" {
 		upperDecl := strings.ToUpper(declType)
 		isTimeType = strings.Contains(upperDecl, "DATE") ||
 			strings.Contains(upperDecl, "TIME") ||
 			strings.Contains(upperDecl, "TIMESTAMP")
 		isBoolType = strings.Contains(upperDecl, "BOOL")
+		isJSONType = strings.Contains(upperDecl, "JSON")
 	}
 
 	switch colType {
@@ -86,13 +132,16 @@ func (r *Rows) scanColumn(i int, colType int, declType string) driver.Value {
 			return intVal != 0
 		}
 		if isTimeType {
-			if t, ok := parseTimeInteger(intVal); ok {
+			if t, ok := parseTimeIntegerUnit(intVal, r.stmt.conn.cfg.timeUnit); ok {
 				return t
 			}
 		}
 		return intVal
 	case SQLITE_REAL:
 		floatVal := sqlite3_column_double(r.stmt.stmt, i)
+		if isBoolType {
+			return floatVal != 0
+		}
 		if isTimeType {
 			if t, ok := parseTimeFloat(floatVal); ok {
 				return t
@@ -102,6 +151,10 @@ func (r *Rows) scanColumn(i int, colType int, declType string) driver.Value {
 	case SQLITE_TEXT:
 		textPtr := sqlite3_column_text(r.stmt.stmt, i)
 		length := sqlite3_column_bytes(r.stmt.stmt, i)
+		if isJSONType {
+			// Raw bytes scan directly into json.RawMessage.
+			return r.columnBytes(textPtr, length)
+		}
 		textVal := goStringN(textPtr, length)
 		if isTimeType {
 			if t, ok := parseTimeString(textVal); ok {
@@ -112,15 +165,25 @@ func (r *Rows) scanColumn(i int, colType int, declType string) driver.Value {
 	case SQLITE_BLOB:
 		blobPtr := sqlite3_column_blob(r.stmt.stmt, i)
 		length := sqlite3_column_bytes(r.stmt.stmt, i)
-		if length == 0 {
-			return []byte{}
-		}
-		return goBytesN(blobPtr, length)
+		return r.columnBytes(blobPtr, length)
 	default:
 		return nil
 	}
 }
 
+// columnBytes returns the n bytes of a column value at ptr. With _zero_copy
+// on the slice aliases SQLite's buffer, which stays valid only until the next
+// step; database/sql copies it for *[]byte and *any destinations, so
+// *sql.RawBytes scans avoid the copy entirely. Otherwise the bytes are copied
+// so that every destination, including json.RawMessage and Scanners, may
+// keep them.
+func (r *Rows) columnBytes(ptr uintptr, n int) []byte {
+	if r.stmt.conn.cfg.zeroCopy {
+		return cBytes(ptr, n)
+	}
+	return bytes.Clone(cBytes(ptr, n))
+}
+
 func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
 	if index < 0 || index >= len(r.columns) {
 		return ""
@@ -148,6 +211,20 @@ func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
 	}
 }
 
+// ColumnMetadata returns the schema, table and table column that result column
+// index is read from. All three are empty when the column is an expression or
+// the library was built without SQLITE_ENABLE_COLUMN_METADATA.
+func (r *Rows) ColumnMetadata(index int) (database, table, origin string) {
+	if index < 0 || index >= len(r.columns) || sqlite3_column_origin_name == nil {
+		return "", "", ""
+	}
+
+	database = goString(sqlite3_column_database_name(r.stmt.stmt, index))
+	table = goString(sqlite3_column_table_name(r.stmt.stmt, index))
+	origin = goString(sqlite3_column_origin_name(r.stmt.stmt, index))
+	return database, table, origin
+}
+
 func (r *Rows) ColumnTypeLength(index int) (int64, bool) {
 	colType := sqlite3_column_type(r.stmt.stmt, index)
 	switch colType {
@@ -159,12 +236,42 @@ func (r *Rows) ColumnTypeLength(index int) (int64, bool) {
 	}
 }
 
-func (r *Rows) ColumnTypeNullable(index int) (bool, bool) {
-	return true, true
+// ColumnTypeNullable reports whether the table column behind result column
+// index accepts NULL. Columns declared NOT NULL and INTEGER PRIMARY KEY
+// columns do not. Expressions, and every column when the library lacks column
+// metadata, are reported as nullable.
+func (r *Rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	database, table, origin := r.ColumnMetadata(index)
+	if origin == "" || sqlite3_table_column_metadata == nil {
+		return true, true
+	}
+
+	dbPtr, dbPinner := cString(database)
+	defer unpin(dbPinner)
+	tablePtr, tablePinner := cString(table)
+	defer unpin(tablePinner)
+	originPtr, originPinner := cString(origin)
+	defer unpin(originPinner)
+
+	var dataType, collSeq uintptr
+	var notNull, primaryKey, autoinc int32
+	rc := sqlite3_table_column_metadata(r.stmt.conn.db, dbPtr, tablePtr, originPtr, &dataType, &collSeq, &notNull, &primaryKey, &autoinc)
+	if rc != SQLITE_OK {
+		return true, true
+	}
+
+	// Only an INTEGER PRIMARY KEY aliases the rowid and so can never be NULL.
+	rowid := primaryKey != 0 && strings.EqualFold(goString(dataType), "INTEGER")
+	return notNull == 0 && !rowid, true
 }
 
-func (r *Rows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
-	return 0, 0, false
+// ColumnTypePrecisionScale parses the precision and scale from a declared
+// NUMERIC or DECIMAL type such as DECIMAL(10,2). A missing scale is 0.
+func (r *Rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if index < 0 || index >= len(r.columns) {
+		return 0, 0, false
+	}
+	return parseDecimalType(goString(sqlite3_column_decltype(r.stmt.stmt, index)))
 }
 
 func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
@@ -201,12 +308,68 @@ func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 	return reflect.TypeOf(new(any)).Elem()
 }
 
+// HasNextResultSet reports whether statements follow the current one in the
+// query. Statements that return no columns are run and skipped by
+// NextResultSet, so it may still find none.
 func (r *Rows) HasNextResultSet() bool {
-	return false
+	return strings.TrimSpace(r.tail) != ""
 }
 
+// NextResultSet finishes the current statement and advances to the next
+// statement of the query that returns columns, running the ones before it
+// that do not. It returns io.EOF when no such statement is left.
 func (r *Rows) NextResultSet() error {
-	return errors.New("multiple result sets not supported")
+	if r.stmt == nil {
+		return io.EOF
+	}
+
+	conn := r.stmt.conn
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	named := hasNamedArgs(r.args)
+	for strings.TrimSpace(r.tail) != "" {
+		stmt, tail, err := conn.prepareContext(r.ctx, r.tail)
+		if err != nil {
+			return err
+		}
+		r.tail = tail
+		if stmt == nil {
+			break
+		}
+
+		var stmtArgs []driver.NamedValue
+		if stmtArgs, r.args, err = stmt.takeArgs(r.args, named); err != nil {
+			stmt.Close()
+			return err
+		}
+
+		if sqlite3_column_count(stmt.stmt) == 0 {
+			_, err := stmt.ExecContext(r.ctx, stmtArgs)
+			stmt.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		rows, err := stmt.QueryContext(r.ctx, stmtArgs)
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+
+		next := rows.(*Rows)
+		r.stmt, r.columns = next.stmt, next.columns
+		r.done, r.stepped, r.ownsStmt, r.closed = false, false, true, false
+		return nil
+	}
+
+	if !named && len(r.args) > 0 {
+		return fmt.Errorf("%d arguments left unused", len(r.args))
+	}
+	return io.EOF
 }
 
 var (
@@ -218,3 +381,34 @@ var (
 	_ driver.RowsColumnTypeScanType         = (*Rows)(nil)
 	_ driver.RowsNextResultSet              = (*Rows)(nil)
 )
+
+func parseDecimalType(declType string) (precision, scale int64, ok bool) {
+	upper := strings.ToUpper(declType)
+	if !strings.Contains(upper, "NUM") && !strings.Contains(upper, "DEC") {
+		return 0, 0, false
+	}
+
+	open := strings.IndexByte(upper, '(')
+	end := strings.LastIndexByte(upper, ')')
+	if open < 0 || end < open {
+		return 0, 0, false
+	}
+
+	args := strings.Split(upper[open+1:end], ",")
+	if len(args) > 2 {
+		return 0, 0, false
+	}
+
+	precision, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil || precision < 0 {
+		return 0, 0, false
+	}
+	if len(args) == 2 {
+		scale, err = strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+		if err != nil || scale < 0 {
+			return 0, 0, false
+		}
+	}
+
+	return precision, scale, true
+}
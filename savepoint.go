@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Savepoint starts a new savepoint with the given name. Outside of a
+// transaction it behaves like BEGIN DEFERRED.
+func (c *Conn) Savepoint(name string) error {
+	return c.execSavepoint("SAVEPOINT "+quoteIdentifier(name), "savepoint")
+}
+
+// ReleaseSavepoint releases the named savepoint and every savepoint nested
+// inside it, keeping their changes.
+func (c *Conn) ReleaseSavepoint(name string) error {
+	return c.execSavepoint("RELEASE "+quoteIdentifier(name), "release savepoint")
+}
+
+// RollbackSavepoint undoes all changes made since the named savepoint was
+// started. The savepoint itself stays active.
+func (c *Conn) RollbackSavepoint(name string) error {
+	return c.execSavepoint("ROLLBACK TO "+quoteIdentifier(name), "rollback to savepoint")
+}
+
+func (c *Conn) execSavepoint(query, op string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return driver.ErrBadConn
+	}
+
+	rc := c.exec(query)
+	if rc != SQLITE_OK {
+		return fmt.Errorf("%s failed: %w", op, c.lastError())
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package sqlite
+
+import "database/sql"
+
+// ScanValues scans the current row of rows into a new slice with one value
+// per column: int64, float64, bool, string, []byte, time.Time or nil, as the
+// driver returned it. Call it after rows.Next, like rows.Scan.
+func ScanValues(rows *sql.Rows) ([]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
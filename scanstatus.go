@@ -0,0 +1,54 @@
+package sqlite
+
+import "unsafe"
+
+// ScanStatus describes one loop of a statement's query plan, as reported by
+// sqlite3_stmt_scanstatus.
+type ScanStatus struct {
+	Loops    int64   // Number of times the loop has run
+	Visits   int64   // Total rows visited by the loop
+	Estimate float64 // Planner's estimate of rows visited per loop
+	Name     string  // Table or index scanned
+	Explain  string  // EXPLAIN QUERY PLAN description of the loop
+	SelectID int     // EXPLAIN QUERY PLAN select id of the loop
+}
+
+// ScanStatus returns the per-loop counters gathered while the statement ran.
+// It returns nil when SQLite was built without SQLITE_ENABLE_STMT_SCANSTATUS.
+func (s *Stmt) ScanStatus() []ScanStatus {
+	if sqlite3_stmt_scanstatus == nil || s.closed {
+		return nil
+	}
+
+	var loops []ScanStatus
+	for idx := 0; ; idx++ {
+		var status ScanStatus
+		var namePtr, explainPtr uintptr
+		var selectID int32
+
+		if sqlite3_stmt_scanstatus(s.stmt, idx, SQLITE_SCANSTAT_NLOOP, unsafe.Pointer(&status.Loops)) != 0 {
+			break
+		}
+		sqlite3_stmt_scanstatus(s.stmt, idx, SQLITE_SCANSTAT_NVISIT, unsafe.Pointer(&status.Visits))
+		sqlite3_stmt_scanstatus(s.stmt, idx, SQLITE_SCANSTAT_EST, unsafe.Pointer(&status.Estimate))
+		sqlite3_stmt_scanstatus(s.stmt, idx, SQLITE_SCANSTAT_NAME, unsafe.Pointer(&namePtr))
+		sqlite3_stmt_scanstatus(s.stmt, idx, SQLITE_SCANSTAT_EXPLAIN, unsafe.Pointer(&explainPtr))
+		sqlite3_stmt_scanstatus(s.stmt, idx, SQLITE_SCANSTAT_SELECTID, unsafe.Pointer(&selectID))
+
+		status.Name = goString(namePtr)
+		status.Explain = goString(explainPtr)
+		status.SelectID = int(selectID)
+		loops = append(loops, status)
+	}
+
+	return loops
+}
+
+// ResetScanStatus zeroes the counters reported by ScanStatus. It does nothing
+// when SQLite was built without SQLITE_ENABLE_STMT_SCANSTATUS.
+func (s *Stmt) ResetScanStatus() {
+	if sqlite3_stmt_scanstatus_reset == nil || s.closed {
+		return
+	}
+	sqlite3_stmt_scanstatus_reset(s.stmt)
+}
@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// BatchResult is the outcome of one statement run by ExecScript.
+type BatchResult struct {
+	SQL          string // Text of the statement
+	RowsAffected int64  // Rows changed by this statement, 0 for statements that change none
+	LastInsertID int64  // Connection's last insert rowid after this statement
+}
+
+// ExecScript executes every statement in script in order and reports each
+// one's result. Positional args are consumed from args as each statement
+// needs them, while sql.Named args are offered to every statement. Execution
+// stops at the first failing statement; the results of the statements before
+// it are returned along with the error.
+func (c *Conn) ExecScript(script string, args ...any) ([]BatchResult, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if arg, ok := arg.(sql.NamedArg); ok {
+			named[i].Name, named[i].Value = arg.Name, arg.Value
+		}
+	}
+
+	return c.execScript(context.Background(), script, named)
+}
+
+func (c *Conn) execScript(ctx context.Context, query string, args []driver.NamedValue) ([]BatchResult, error) {
+	var results []BatchResult
+	named := hasNamedArgs(args)
+
+	for {
+		stmt, tail, err := c.prepareContext(ctx, query)
+		if err != nil {
+			return results, err
+		}
+
+		if stmt != nil {
+			var stmtArgs []driver.NamedValue
+			stmtArgs, args, err = stmt.takeArgs(args, named)
+			if err != nil {
+				stmt.Close()
+				return results, err
+			}
+
+			before := c.TotalChanges()
+			res, err := stmt.ExecContext(ctx, stmtArgs)
+			stmt.Close()
+			if err != nil {
+				return results, err
+			}
+
+			result := BatchResult{SQL: strings.TrimSpace(stmt.query)}
+			result.LastInsertID, _ = res.LastInsertId()
+			// sqlite3_changes keeps the count of the last INSERT, UPDATE or
+			// DELETE, so only trust it when this statement changed rows.
+			if c.TotalChanges() != before {
+				result.RowsAffected, _ = res.RowsAffected()
+			}
+			results = append(results, result)
+		}
+
+		if strings.TrimSpace(tail) == "" {
+			break
+		}
+		query = tail
+	}
+
+	if !named && len(args) > 0 {
+		return results, fmt.Errorf("%d arguments left unused", len(args))
+	}
+
+	return results, nil
+}
+
+// takeArgs splits off the arguments of one statement of a script. Named
+// arguments are offered to every statement, which binds the ones it uses;
+// positional ones are consumed in order.
+func (s *Stmt) takeArgs(args []driver.NamedValue, named bool) (stmtArgs, rest []driver.NamedValue, err error) {
+	if named {
+		return args, args, nil
+	}
+
+	n := s.NumInput()
+	if n > len(args) {
+		return nil, nil, fmt.Errorf("not enough arguments: statement needs %d, %d left", n, len(args))
+	}
+
+	stmtArgs = make([]driver.NamedValue, n)
+	for i := range stmtArgs {
+		stmtArgs[i] = args[i]
+		stmtArgs[i].Ordinal = i + 1
+	}
+	return stmtArgs, args[n:], nil
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Status reads the process-wide library counter op, one of the
+// SQLITE_STATUS_* constants, returning its current value and highest value
+// since the last reset. reset restarts the highwater mark at the current
+// value.
+func Status(op int, reset bool) (current, highwater int64, err error) {
+	if err := openSQLite3(); err != nil {
+		return 0, 0, err
+	}
+
+	resetFlag := 0
+	if reset {
+		resetFlag = 1
+	}
+
+	if rc := sqlite3_status64(op, &current, &highwater, resetFlag); rc != SQLITE_OK {
+		return 0, 0, fmt.Errorf("status %d failed: %s", op, errorString(rc))
+	}
+
+	return current, highwater, nil
+}
+
+// DBStatus reads the connection counter op, one of the SQLITE_DBSTATUS_*
+// constants, returning its current value and highwater mark. Not every
+// counter tracks a highwater mark; the cache hit and miss counters report
+// their totals in current. reset restarts the counter or highwater mark.
+func (c *Conn) DBStatus(op int, reset bool) (current, highwater int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed.Load() {
+		return 0, 0, driver.ErrBadConn
+	}
+
+	resetFlag := 0
+	if reset {
+		resetFlag = 1
+	}
+
+	var cur, hiwtr int32
+	if rc := sqlite3_db_status(c.db, op, &cur, &hiwtr, resetFlag); rc != SQLITE_OK {
+		return 0, 0, fmt.Errorf("db status %d failed: %s", op, errorString(rc))
+	}
+
+	return int(cur), int(hiwtr), nil
+}
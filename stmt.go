@@ -2,40 +2,118 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// stmtsPrepared and stmtsFinalized count every statement compiled and
+// finalized by the driver, so leaks show up as a growing difference.
+var (
+	stmtsPrepared  atomic.Int64
+	stmtsFinalized atomic.Int64
+)
+
+// StatementBalance returns how many statements the driver has prepared and
+// finalized across all connections. A difference that keeps growing after
+// statements and connections are closed points to a leaked statement. Idle
+// statements held by the statement cache count as prepared until their
+// connection closes.
+func StatementBalance() (prepared, finalized int64) {
+	return stmtsPrepared.Load(), stmtsFinalized.Load()
+}
+
 type Stmt struct {
-	conn   *Conn
-	stmt   uintptr
-	query  string
-	closed bool
+	conn     *Conn
+	stmt     uintptr
+	query    string
+	tail     string
+	cacheKey string // Full SQL text the statement was prepared from
+	closed   bool
+	stale    bool // No longer compiles against the schema, so never cached
+	activeID int  // ID in the connection's active statements, zero when not running
 }
 
+// ErrSchemaChanged is wrapped by errors from statements that no longer
+// compile because the schema changed after they were prepared, for example
+// when a table they read was dropped. The wrapped *Error names the missing
+// object. Such statements are evicted from the statement cache.
+var ErrSchemaChanged = errors.New("statement invalidated by a schema change")
+
 func (s *Stmt) Close() error {
 	if s.closed {
 		return nil
 	}
 
+	if s.conn.release(s) {
+		return nil
+	}
+
+	return s.finalize()
+}
+
+func (s *Stmt) finalize() error {
 	s.conn.stmts.Delete(s.stmt)
+	s.untrack()
 
 	rc := sqlite3_finalize(s.stmt)
+	stmtsFinalized.Add(1)
+	s.closed = true
 	if rc != SQLITE_OK {
 		return fmt.Errorf("finalize failed: %s", errorString(rc))
 	}
 
-	s.closed = true
 	return nil
 }
 
+// stepError returns the error of a failed step. SQLite recompiles statements
+// after a schema change, so a step failing with SQLITE_ERROR may mean the
+// statement no longer compiles; checking that by compiling its SQL again
+// tells this apart from errors raised while running it.
+func (s *Stmt) stepError() error {
+	code := sqlite3_extended_errcode(s.conn.db)
+	err := s.conn.lastError()
+	if code != SQLITE_ERROR || s.compiles() {
+		return err
+	}
+
+	s.stale = true
+	return fmt.Errorf("%w: %w", ErrSchemaChanged, err)
+}
+
+// compiles reports whether the statement's SQL still compiles.
+func (s *Stmt) compiles() bool {
+	queryPtr, pinner := cString(s.query)
+	defer unpin(pinner)
+
+	var stmtPtr uintptr
+	if sqlite3_prepare_v2(s.conn.db, queryPtr, -1, &stmtPtr, nil) != SQLITE_OK {
+		return false
+	}
+	sqlite3_finalize(stmtPtr)
+	return true
+}
+
 func (s *Stmt) NumInput() int {
 	return sqlite3_bind_parameter_count(s.stmt)
 }
 
+// Readonly reports whether the statement makes no direct changes to the
+// database file. Transaction control statements such as BEGIN also count as
+// read-only.
+func (s *Stmt) Readonly() bool {
+	return sqlite3_stmt_readonly(s.stmt) != 0
+}
+
 func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
 	namedArgs := make([]driver.NamedValue, len(args))
 	for i, arg := range args {
@@ -54,24 +132,35 @@ func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (drive
 	default:
 	}
 
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
 	if s.closed {
 		return nil, errors.New("statement closed")
 	}
 
-	if err := s.bind(args); err != nil {
+	if err := s.bind(ctx, args); err != nil {
 		return nil, err
 	}
 
-	rc := sqlite3_step(s.stmt)
-	defer sqlite3_reset(s.stmt)
+	restore := s.conn.overrideBusyTimeout(ctx)
+	rc := s.step(ctx)
+	restore()
+	defer s.reset()
+
+	// Statements with a RETURNING clause produce rows; step through them so
+	// the statement runs to completion before the result is reported.
+	for rc == SQLITE_ROW {
+		rc = sqlite3_step(s.stmt)
+	}
 
-	if rc != SQLITE_DONE && rc != SQLITE_ROW {
-		return nil, fmt.Errorf("exec failed: %s", getErrorMessage(s.conn.db))
+	if rc != SQLITE_DONE {
+		return nil, fmt.Errorf("exec failed: %w", s.stepError())
 	}
 
 	return &Result{
 		lastInsertID: sqlite3_last_insert_rowid(s.conn.db),
-		rowsAffected: int64(sqlite3_changes(s.conn.db)),
+		rowsAffected: changes(s.conn.db),
 	}, nil
 }
 
@@ -93,11 +182,14 @@ func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driv
 	default:
 	}
 
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
 	if s.closed {
 		return nil, errors.New("statement closed")
 	}
 
-	if err := s.bind(args); err != nil {
+	if err := s.bind(ctx, args); err != nil {
 		return nil, err
 	}
 
@@ -106,6 +198,12 @@ func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driv
 	for i := 0; i < columnCount; i++ {
 		namePtr := sqlite3_column_name(s.stmt, i)
 		columns[i] = goString(namePtr)
+		switch s.conn.cfg.columnCase {
+		case "upper":
+			columns[i] = strings.ToUpper(columns[i])
+		case "lower":
+			columns[i] = strings.ToLower(columns[i])
+		}
 	}
 
 	return &Rows{
@@ -115,36 +213,106 @@ func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driv
 	}, nil
 }
 
-func (s *Stmt) bind(args []driver.NamedValue) error {
+// reset rewinds the statement and clears its bindings so that values from
+// a previous execution can never leak into the next one.
+func (s *Stmt) reset() {
+	s.untrack()
+	sqlite3_reset(s.stmt)
+	sqlite3_clear_bindings(s.stmt)
+}
+
+// bind binds args to the statement, checking ctx between parameters so that
+// binding large values can be abandoned. On failure every binding made so far
+// is cleared, releasing SQLite's copies of them.
+//
+// Named arguments bind to the :name, @name or $name parameter. Named
+// arguments the statement does not use are ignored, so one set of arguments
+// can serve several statements, but every parameter must be given a value
+// and positional arguments must have a parameter to bind to.
+func (s *Stmt) bind(ctx context.Context, args []driver.NamedValue) (err error) {
 	expectedArgs := s.NumInput()
-	if len(args) != expectedArgs {
+	named := hasNamedArgs(args)
+	if !named && len(args) != expectedArgs {
 		return fmt.Errorf("expected %d arguments, got %d", expectedArgs, len(args))
 	}
 
-	for _, arg := range args {
-		idx := arg.Ordinal
-		if idx <= 0 {
-			continue
+	defer func() {
+		if err != nil {
+			sqlite3_clear_bindings(s.stmt)
 		}
+	}()
+
+	var bound []bool
+	if named {
+		bound = make([]bool, expectedArgs)
+	}
 
-		value := arg.Value
+	for _, arg := range args {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-		if valuer, ok := value.(driver.Valuer); ok {
-			var err error
-			value, err = valuer.Value()
-			if err != nil {
-				return fmt.Errorf("valuer error at position %d: %w", idx, err)
+		idx := arg.Ordinal
+		if arg.Name != "" {
+			idx = s.parameterIndex(arg.Name)
+		}
+		if idx <= 0 || idx > expectedArgs {
+			// Only named arguments may go unused.
+			if arg.Name == "" {
+				return fmt.Errorf("argument %d out of range", arg.Ordinal)
 			}
+			continue
 		}
 
-		if err := s.bindValue(idx, value); err != nil {
+		if err := s.bindValue(idx, arg.Value); err != nil {
 			return err
 		}
+		if named {
+			bound[idx-1] = true
+		}
+	}
+
+	for i, ok := range bound {
+		if !ok {
+			return fmt.Errorf("missing argument for parameter %s", s.parameterName(i+1))
+		}
 	}
 
 	return nil
 }
 
+func hasNamedArgs(args []driver.NamedValue) bool {
+	for _, arg := range args {
+		if arg.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterIndex returns the index of the parameter called name with any of
+// SQLite's prefixes, or 0 if the statement has none.
+func (s *Stmt) parameterIndex(name string) int {
+	for _, prefix := range []string{":", "@", "$"} {
+		namePtr, pinner := cString(prefix + name)
+		idx := sqlite3_bind_parameter_index(s.stmt, namePtr)
+		unpin(pinner)
+		if idx > 0 {
+			return idx
+		}
+	}
+	return 0
+}
+
+// parameterName returns the name of parameter idx as written in the SQL, or
+// ?idx for nameless parameters.
+func (s *Stmt) parameterName(idx int) string {
+	if name := goString(sqlite3_bind_parameter_name(s.stmt, idx)); name != "" {
+		return name
+	}
+	return "?" + strconv.Itoa(idx)
+}
+
 func (s *Stmt) bindValue(idx int, value any) error {
 	var rc int
 
@@ -156,6 +324,43 @@ func (s *Stmt) bindValue(idx int, value any) error {
 		return nil
 	}
 
+	// A nil pointer binds NULL. Check before calling Value or MarshalJSON,
+	// which panic through a nil *sql.NullString or *time.Time.
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return s.bindValue(idx, nil)
+	}
+
+	// The sql.Null* types are bound without going through their Valuer.
+	switch v := value.(type) {
+	case sql.NullString:
+		return s.bindNullable(idx, v.String, v.Valid)
+	case sql.NullInt64:
+		return s.bindNullable(idx, v.Int64, v.Valid)
+	case sql.NullInt32:
+		return s.bindNullable(idx, v.Int32, v.Valid)
+	case sql.NullInt16:
+		return s.bindNullable(idx, v.Int16, v.Valid)
+	case sql.NullByte:
+		return s.bindNullable(idx, v.Byte, v.Valid)
+	case sql.NullFloat64:
+		return s.bindNullable(idx, v.Float64, v.Valid)
+	case sql.NullBool:
+		return s.bindNullable(idx, v.Bool, v.Valid)
+	case sql.NullTime:
+		return s.bindNullable(idx, v.Time, v.Valid)
+	case driver.Valuer:
+		resolved, err := v.Value()
+		if err != nil {
+			return fmt.Errorf("valuer error at position %d: %w", idx, err)
+		}
+		// Resolve only once, so a Valuer returning a Valuer (or itself)
+		// cannot recurse forever.
+		if !driver.IsValue(resolved) {
+			return fmt.Errorf("valuer at position %d returned unsupported type %T", idx, resolved)
+		}
+		return s.bindValue(idx, resolved)
+	}
+
 	switch v := value.(type) {
 	case int64:
 		rc = sqlite3_bind_int64(s.stmt, idx, v)
@@ -168,7 +373,7 @@ func (s *Stmt) bindValue(idx int, value any) error {
 	case int8:
 		rc = sqlite3_bind_int64(s.stmt, idx, int64(v))
 	case uint64:
-		rc = sqlite3_bind_int64(s.stmt, idx, int64(v))
+		return s.bindUint64(idx, v)
 	case uint32:
 		rc = sqlite3_bind_int64(s.stmt, idx, int64(v))
 	case uint16:
@@ -176,7 +381,7 @@ func (s *Stmt) bindValue(idx int, value any) error {
 	case uint8:
 		rc = sqlite3_bind_int64(s.stmt, idx, int64(v))
 	case uint:
-		rc = sqlite3_bind_int64(s.stmt, idx, int64(v))
+		return s.bindUint64(idx, uint64(v))
 	case bool:
 		if v {
 			rc = sqlite3_bind_int64(s.stmt, idx, 1)
@@ -184,36 +389,177 @@ func (s *Stmt) bindValue(idx int, value any) error {
 			rc = sqlite3_bind_int64(s.stmt, idx, 0)
 		}
 	case float64:
-		rc = sqlite3_bind_double(s.stmt, idx, v)
+		return s.bindFloat(idx, v)
 	case float32:
-		rc = sqlite3_bind_double(s.stmt, idx, float64(v))
+		return s.bindFloat(idx, float64(v))
 	case string:
-		strPtr, pinner := cString(v)
+		strPtr, pinner := pinString(v)
 		defer unpin(pinner)
 		rc = sqlite3_bind_text(s.stmt, idx, strPtr, len(v), SQLITE_TRANSIENT)
 	case []byte:
-		if len(v) == 0 {
-			rc = sqlite3_bind_blob(s.stmt, idx, 0, 0, SQLITE_STATIC)
+		// A NULL pointer would make sqlite3_bind_blob bind NULL, so only a
+		// nil slice does; an empty one is bound as a zero-length blob.
+		if v == nil {
+			rc = sqlite3_bind_null(s.stmt, idx)
+		} else if len(v) == 0 {
+			rc = sqlite3_bind_zeroblob(s.stmt, idx, 0)
 		} else {
 			blobPtr, pinner := allocateBytes(v)
 			defer unpin(pinner)
 			rc = sqlite3_bind_blob(s.stmt, idx, blobPtr, len(v), SQLITE_TRANSIENT)
 		}
+	case *big.Int:
+		return s.bindValue(idx, v.String())
+	case *big.Rat:
+		return s.bindValue(idx, v.RatString())
 	case time.Time:
-		strPtr, pinner := cString(v.Format(time.RFC3339Nano))
+		if s.conn.cfg.normalizeUTC {
+			v = v.UTC()
+		}
+		if s.conn.cfg.timePrecision > 0 {
+			v = v.Truncate(s.conn.cfg.timePrecision)
+		}
+		text := v.Format(time.RFC3339Nano)
+		if s.conn.cfg.timeFormat != "" {
+			// Match the text SQLite's date functions produce, so stored
+			// values compare and sort correctly against them.
+			text = v.UTC().Format(s.conn.cfg.timeFormat)
+		}
+		strPtr, pinner := pinString(text)
 		defer unpin(pinner)
-		rc = sqlite3_bind_text(s.stmt, idx, strPtr, -1, SQLITE_TRANSIENT)
+		rc = sqlite3_bind_text(s.stmt, idx, strPtr, len(text), SQLITE_TRANSIENT)
+	case json.RawMessage:
+		if v == nil {
+			return s.bindValue(idx, nil)
+		}
+		return s.bindValue(idx, string(v))
+	case json.Marshaler:
+		data, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("marshal JSON at position %d: %w", idx, err)
+		}
+		return s.bindValue(idx, string(data))
 	default:
+		// Pointers are common for optional fields; nil ones bound NULL above.
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer {
+			return s.bindValue(idx, rv.Elem().Interface())
+		}
 		return fmt.Errorf("unsupported type %T at position %d", value, idx)
 	}
 
 	if rc != SQLITE_OK {
-		return fmt.Errorf("bind failed at position %d: %s", idx, getErrorMessage(s.conn.db))
+		return fmt.Errorf("bind failed at position %d: %w", idx, s.conn.lastError())
+	}
+
+	return nil
+}
+
+func (s *Stmt) bindNullable(idx int, value any, valid bool) error {
+	if !valid {
+		return s.bindValue(idx, nil)
+	}
+	return s.bindValue(idx, value)
+}
+
+// bindUint64 binds v as an integer, or as decimal text when it exceeds
+// math.MaxInt64 and _uint64_text is on. SQLite integers are signed, so such
+// values are otherwise rejected rather than stored as negative numbers.
+func (s *Stmt) bindUint64(idx int, v uint64) error {
+	if v <= math.MaxInt64 {
+		return s.bindValue(idx, int64(v))
 	}
+	if !s.conn.cfg.uint64Text {
+		return fmt.Errorf("uint64 value %d at position %d overflows SQLite's signed 64-bit integer", v, idx)
+	}
+	return s.bindValue(idx, strconv.FormatUint(v, 10))
+}
 
+// bindFloat binds v as a REAL. SQLite stores NaN as NULL and keeps infinities
+// as infinite REALs; with _strict_float on both are rejected instead.
+func (s *Stmt) bindFloat(idx int, v float64) error {
+	if s.conn.cfg.strictFloat && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		return fmt.Errorf("float value %v at position %d is not finite", v, idx)
+	}
+	if rc := sqlite3_bind_double(s.stmt, idx, v); rc != SQLITE_OK {
+		return fmt.Errorf("bind failed at position %d: %w", idx, s.conn.lastError())
+	}
 	return nil
 }
 
+// AffinityOf reports the storage class (SQLITE_INTEGER, SQLITE_REAL,
+// SQLITE_TEXT, SQLITE_BLOB or SQLITE_NULL) a value is bound as. Valuers are
+// resolved first and pointers dereferenced, with nil ones bound as NULL. It
+// returns 0 for values the driver cannot bind, including uint64 values above
+// math.MaxInt64; Conn.AffinityOf reports those as SQLITE_TEXT when
+// _uint64_text is on.
+func AffinityOf(v driver.Value) int {
+	return affinityOf(v, false)
+}
+
+// AffinityOf is like the package-level AffinityOf, but follows the
+// connection's _uint64_text setting.
+func (c *Conn) AffinityOf(v driver.Value) int {
+	return affinityOf(v, c.cfg.uint64Text)
+}
+
+func affinityOf(v driver.Value, uint64Text bool) int {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return SQLITE_NULL
+	}
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || !driver.IsValue(value) {
+			return 0
+		}
+		v = value
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return SQLITE_NULL
+	case int64, int, int32, int16, int8, uint32, uint16, uint8, bool:
+		return SQLITE_INTEGER
+	case uint64:
+		return uint64Affinity(v, uint64Text)
+	case uint:
+		return uint64Affinity(uint64(v), uint64Text)
+	case float64, float32:
+		return SQLITE_REAL
+	case json.RawMessage:
+		if v == nil {
+			return SQLITE_NULL
+		}
+		return SQLITE_TEXT
+	case string, time.Time, *big.Int, *big.Rat, json.Marshaler:
+		return SQLITE_TEXT
+	case []byte:
+		// Like bindValue, a nil slice binds NULL and an empty one a blob.
+		if v == nil {
+			return SQLITE_NULL
+		}
+		return SQLITE_BLOB
+	default:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+			return affinityOf(rv.Elem().Interface(), uint64Text)
+		}
+		return 0
+	}
+}
+
+// uint64Affinity mirrors bindUint64: values above math.MaxInt64 bind as TEXT
+// with _uint64_text on and fail otherwise.
+func uint64Affinity(v uint64, uint64Text bool) int {
+	switch {
+	case v <= math.MaxInt64:
+		return SQLITE_INTEGER
+	case uint64Text:
+		return SQLITE_TEXT
+	default:
+		return 0
+	}
+}
+
 func (s *Stmt) CheckNamedValue(nv *driver.NamedValue) error {
 	return checkNamedValue(nv)
 }
@@ -223,27 +569,39 @@ func checkNamedValue(nv *driver.NamedValue) error {
 		return nil
 	}
 
-	if _, ok := nv.Value.(driver.Valuer); ok {
+	switch nv.Value.(type) {
+	case driver.Valuer, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, string, []byte, time.Time, *big.Int, *big.Rat, json.Marshaler:
 		return nil
 	}
 
 	v := reflect.ValueOf(nv.Value)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		// bindValue dereferences pointers to types it binds directly.
+		elem := driver.NamedValue{Ordinal: nv.Ordinal, Value: v.Elem().Interface()}
+		if checkNamedValue(&elem) == nil {
+			return nil
+		}
+		return driver.ErrSkip
+	}
+
+	// database/sql converts named basic types to their underlying type, so
+	// leave those to its default converter.
 	switch v.Kind() {
 	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Float32, reflect.Float64, reflect.String:
-		return nil
+		return driver.ErrSkip
 	case reflect.Slice:
 		if v.Type().Elem().Kind() == reflect.Uint8 {
-			return nil
+			return driver.ErrSkip
 		}
 	}
 
-	if _, ok := nv.Value.(time.Time); ok {
-		return nil
-	}
-
-	return driver.ErrSkip
+	return fmt.Errorf("unsupported type %T for argument %d, implement driver.Valuer to bind it", nv.Value, nv.Ordinal)
 }
 
 var (
@@ -0,0 +1,65 @@
+package sqlite
+
+import "container/list"
+
+// stmtCache is an LRU of idle prepared statements keyed by their SQL text.
+// Statements are removed while in use and put back when closed, so a cached
+// statement is never shared. Callers must hold the connection mutex.
+type stmtCache struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get removes and returns the idle statement compiled from query, if any.
+func (sc *stmtCache) get(query string) *Stmt {
+	elem, ok := sc.items[query]
+	if !ok {
+		return nil
+	}
+
+	sc.ll.Remove(elem)
+	delete(sc.items, query)
+	return elem.Value.(*Stmt)
+}
+
+// put adds s to the cache and returns the statement that no longer fits,
+// which the caller must finalize. That is either the least recently used
+// entry or s itself when another idle statement for the same SQL is cached.
+// Putting a statement that is already cached changes nothing.
+func (sc *stmtCache) put(s *Stmt) *Stmt {
+	if elem, ok := sc.items[s.cacheKey]; ok {
+		if elem.Value.(*Stmt) == s {
+			return nil
+		}
+		return s
+	}
+
+	sc.items[s.cacheKey] = sc.ll.PushFront(s)
+	if sc.ll.Len() <= sc.size {
+		return nil
+	}
+
+	oldest := sc.ll.Back()
+	sc.ll.Remove(oldest)
+	evicted := oldest.Value.(*Stmt)
+	delete(sc.items, evicted.cacheKey)
+	return evicted
+}
+
+func (sc *stmtCache) len() int {
+	return sc.ll.Len()
+}
+
+func (sc *stmtCache) clear() {
+	sc.ll.Init()
+	clear(sc.items)
+}
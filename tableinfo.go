@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// ColumnInfo describes a table column as reported by PRAGMA table_xinfo.
+type ColumnInfo struct {
+	CID         int
+	Name        string
+	Type        string // Declared type, empty if none
+	NotNull     bool
+	Default     string // Default value expression, empty if none
+	PrimaryKey  int    // 1-based position in the primary key, 0 if not part of it
+	IsGenerated bool   // VIRTUAL or STORED generated column
+	Hidden      bool   // Hidden column of a virtual table, such as FTS5's rank
+}
+
+// TableInfo returns the columns of table in the main schema, including
+// generated columns and the hidden columns of virtual tables.
+func (c *Conn) TableInfo(table string) ([]ColumnInfo, error) {
+	stmt, _, err := c.prepare(`SELECT cid, name, type, "notnull", dflt_value, pk, hidden FROM pragma_table_xinfo(?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := stmt.bindValue(1, table); err != nil {
+		return nil, err
+	}
+
+	var columns []ColumnInfo
+	for rc := stmt.step(context.Background()); rc != SQLITE_DONE; rc = sqlite3_step(stmt.stmt) {
+		if rc != SQLITE_ROW {
+			return nil, fmt.Errorf("table info failed: %w", c.lastError())
+		}
+
+		hidden := sqlite3_column_int64(stmt.stmt, 6)
+		columns = append(columns, ColumnInfo{
+			CID:         int(sqlite3_column_int64(stmt.stmt, 0)),
+			Name:        goString(sqlite3_column_text(stmt.stmt, 1)),
+			Type:        goString(sqlite3_column_text(stmt.stmt, 2)),
+			NotNull:     sqlite3_column_int64(stmt.stmt, 3) != 0,
+			Default:     goString(sqlite3_column_text(stmt.stmt, 4)),
+			PrimaryKey:  int(sqlite3_column_int64(stmt.stmt, 5)),
+			IsGenerated: hidden == 2 || hidden == 3,
+			Hidden:      hidden == 1,
+		})
+	}
+
+	if columns == nil {
+		return nil, fmt.Errorf("no such table: %s", table)
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// tempConnector removes its database directory when the sql.DB is closed.
+type tempConnector struct {
+	*connector
+	dir string
+}
+
+func (c *tempConnector) Close() error {
+	return os.RemoveAll(c.dir)
+}
+
+// OpenTemp opens a throwaway WAL-mode database in a new directory under
+// os.TempDir, tuned for speed over durability with synchronous=OFF and
+// temp_store=MEMORY. Point TMPDIR at a ramdisk such as /dev/shm to keep it off
+// disk entirely. The directory, including the WAL and shared-memory files, is
+// removed when the returned DB is closed. Options are applied after the
+// defaults, so pragmas passed with WithPragma override them; Config.DSN is
+// ignored.
+func OpenTemp(opts ...Option) (*sql.DB, error) {
+	cfg := Config{
+		Pragmas: []string{"journal_mode=WAL", "synchronous=OFF", "temp_store=MEMORY"},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	dir, err := os.MkdirTemp("", "sqlite-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	cfg.DSN = "file:" + filepath.ToSlash(filepath.Join(dir, "temp.db"))
+	c, err := NewConnector(cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return sql.OpenDB(&tempConnector{connector: c.(*connector), dir: dir}), nil
+}
@@ -5,6 +5,17 @@ import (
 	"sync"
 )
 
+// ConcurrentMap is the subset of map operations shared by ThreadSafeMap and
+// LockedMap
+type ConcurrentMap[K comparable, V any] interface {
+	Store(key K, value V)
+	Load(key K) (value V, ok bool)
+	Delete(key K)
+	Iter() iter.Seq2[K, V]
+	Len() int
+	Clear()
+}
+
 // ThreadSafeMap provides a thread-safe map implementation using generics
 type ThreadSafeMap[K comparable, V any] struct {
 	m sync.Map
@@ -111,3 +122,72 @@ func (tm *ThreadSafeMap[K, V]) Len() int {
 func (tm *ThreadSafeMap[K, V]) Clear() {
 	tm.m.Clear()
 }
+
+// LockedMap is a mutex-guarded map. Unlike ThreadSafeMap its Len is O(1) and
+// Clear keeps the allocated capacity for reuse.
+type LockedMap[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]V
+}
+
+// NewLockedMap creates a new mutex-guarded map with room for capacity entries
+func NewLockedMap[K comparable, V any](capacity int) *LockedMap[K, V] {
+	return &LockedMap[K, V]{m: make(map[K]V, capacity)}
+}
+
+// Store sets the value for a key
+func (lm *LockedMap[K, V]) Store(key K, value V) {
+	lm.mu.Lock()
+	lm.m[key] = value
+	lm.mu.Unlock()
+}
+
+// Load returns the value stored in the map for a key, or zero value if no value is present
+func (lm *LockedMap[K, V]) Load(key K) (value V, ok bool) {
+	lm.mu.Lock()
+	value, ok = lm.m[key]
+	lm.mu.Unlock()
+	return value, ok
+}
+
+// Delete deletes the value for a key
+func (lm *LockedMap[K, V]) Delete(key K) {
+	lm.mu.Lock()
+	delete(lm.m, key)
+	lm.mu.Unlock()
+}
+
+// Iter returns an iterator over a snapshot of the key-value pairs, so the map
+// may be modified while iterating
+func (lm *LockedMap[K, V]) Iter() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		lm.mu.Lock()
+		keys := make([]K, 0, len(lm.m))
+		values := make([]V, 0, len(lm.m))
+		for k, v := range lm.m {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		lm.mu.Unlock()
+
+		for i := range keys {
+			if !yield(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of elements in the map
+func (lm *LockedMap[K, V]) Len() int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return len(lm.m)
+}
+
+// Clear removes all entries from the map
+func (lm *LockedMap[K, V]) Clear() {
+	lm.mu.Lock()
+	clear(lm.m)
+	lm.mu.Unlock()
+}
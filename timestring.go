@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// TimeString scans a date and time from any SQLite value, including text
+// from columns without a declared date or time type such as datetime('now')
+// in a view or expression. It accepts the same text formats, Unix timestamps
+// and Julian day numbers as declared DATETIME columns. A NULL leaves Valid
+// false.
+type TimeString struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements sql.Scanner.
+func (ts *TimeString) Scan(value any) error {
+	ts.Time, ts.Valid = time.Time{}, false
+
+	var ok bool
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		ts.Time, ok = v, true
+	case string:
+		ts.Time, ok = parseTimeString(v)
+	case []byte:
+		ts.Time, ok = parseTimeString(string(v))
+	case int64:
+		ts.Time, ok = parseTimeInteger(v)
+	case float64:
+		ts.Time, ok = parseTimeFloat(v)
+	}
+
+	if !ok {
+		return fmt.Errorf("cannot scan %T %v into TimeString", value, value)
+	}
+
+	ts.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer, binding the time like a time.Time.
+func (ts TimeString) Value() (driver.Value, error) {
+	if !ts.Valid {
+		return nil, nil
+	}
+	return ts.Time, nil
+}
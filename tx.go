@@ -17,20 +17,29 @@ func (t *Tx) Commit() error {
 		return errors.New("transaction already finished")
 	}
 
+	onCommit, err := t.commit()
+	if err != nil {
+		return err
+	}
+
+	if onCommit != nil {
+		onCommit()
+	}
+	return nil
+}
+
+func (t *Tx) commit() (func(), error) {
 	t.conn.mu.Lock()
 	defer t.conn.mu.Unlock()
 
-	queryPtr, pinner := cString("COMMIT")
-	defer unpin(pinner)
-
-	rc := sqlite3_exec(t.conn.db, queryPtr, 0, 0, 0)
+	rc := t.conn.exec("COMMIT")
 	if rc != SQLITE_OK {
-		return fmt.Errorf("commit failed: %s", getErrorMessage(t.conn.db))
+		return nil, fmt.Errorf("commit failed: %w", t.conn.lastError())
 	}
 
 	t.finished = true
 	t.conn.tx = nil
-	return nil
+	return t.conn.onCommit, nil
 }
 
 func (t *Tx) Rollback() error {
@@ -41,12 +50,9 @@ func (t *Tx) Rollback() error {
 	t.conn.mu.Lock()
 	defer t.conn.mu.Unlock()
 
-	queryPtr, pinner := cString("ROLLBACK")
-	defer unpin(pinner)
-
-	rc := sqlite3_exec(t.conn.db, queryPtr, 0, 0, 0)
+	rc := t.conn.exec("ROLLBACK")
 	if rc != SQLITE_OK {
-		return fmt.Errorf("rollback failed: %s", getErrorMessage(t.conn.db))
+		return fmt.Errorf("rollback failed: %w", t.conn.lastError())
 	}
 
 	t.finished = true
@@ -25,6 +25,10 @@ const (
 	unixNanosMin  = 1000000000000000000
 )
 
+// sqliteTimeFormat is the time format of _time_format=sqlite: the format
+// SQLite's date and time functions produce, with microseconds, in UTC.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999"
+
 var timeFormats = []string{
 	time.RFC3339Nano,
 	time.RFC3339,
@@ -104,6 +108,17 @@ func parseTimeInteger(i int64) (time.Time, bool) {
 	return time.Unix(i, 0).UTC(), true
 }
 
+// parseTimeIntegerUnit interprets i as a Unix timestamp in unit, falling
+// back to parseTimeInteger's magnitude heuristics when unit is zero.
+func parseTimeIntegerUnit(i int64, unit time.Duration) (time.Time, bool) {
+	if unit == 0 {
+		return parseTimeInteger(i)
+	}
+
+	perSecond := int64(time.Second / unit)
+	return time.Unix(i/perSecond, (i%perSecond)*int64(unit)).UTC(), true
+}
+
 func parseTimeFloat(f float64) (time.Time, bool) {
 	if f >= julianDayMin && f <= julianDayMax {
 		return julianToTime(f), true
@@ -130,3 +145,20 @@ func timeToJulian(t time.Time) float64 {
 	unixNanos := float64(t.Nanosecond())
 	return unixSeconds/secondsPerDay + julianDay1970 + unixNanos/nanosecondsPerDay
 }
+
+// quoteIdentifier quotes s as an SQL identifier so it can be safely
+// interpolated into statements that do not accept bound parameters.
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards % and _, and the escape character
+// itself, so that s matches literally. Use the result with a backslash
+// escape clause, adding wildcards around it as needed:
+//
+//	db.Query(`SELECT name FROM files WHERE name LIKE ? ESCAPE '\'`, "%"+sqlite.EscapeLike(term)+"%")
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
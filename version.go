@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+var minimumVersion atomic.Int64
+
+// SetMinimumVersion makes opening a connection fail when the loaded SQLite
+// library is older than n, given in the format of Version's number (for
+// example 3035000 for 3.35.0, which added RETURNING). Zero disables the check.
+func SetMinimumVersion(n int) {
+	minimumVersion.Store(int64(n))
+}
+
+func checkMinimumVersion() error {
+	minimum := minimumVersion.Load()
+	if minimum <= 0 {
+		return nil
+	}
+
+	if number := sqlite3_libversion_number(); int64(number) < minimum {
+		return fmt.Errorf("sqlite3 library %s (%d) is older than the required minimum version %d",
+			goString(sqlite3_libversion()), number, minimum)
+	}
+
+	return nil
+}
+
+// Version returns the version of the loaded SQLite library as a string such
+// as "3.45.1" and as a number such as 3045001. It returns "" and 0 if the
+// library cannot be loaded.
+func Version() (string, int) {
+	if err := openSQLite3(); err != nil {
+		return "", 0
+	}
+
+	return goString(sqlite3_libversion()), sqlite3_libversion_number()
+}
+
+// CompileOptions returns the compile-time options the loaded SQLite library
+// was built with, without the SQLITE_ prefix. It returns nil if the library
+// cannot be loaded.
+func CompileOptions() []string {
+	if err := openSQLite3(); err != nil {
+		return nil
+	}
+
+	var options []string
+	for i := 0; ; i++ {
+		opt := sqlite3_compileoption_get(i)
+		if opt == 0 {
+			break
+		}
+		options = append(options, goString(opt))
+	}
+
+	return options
+}
+
+// ThreadSafe returns the threading mode the loaded SQLite library was
+// compiled with: 0 for single-thread, where no connection may be used from
+// more than one goroutine and the _mutex parameter has no effect, 1 for
+// serialized and 2 for multi-thread. It returns -1 if the library cannot be
+// loaded.
+func ThreadSafe() int {
+	if err := openSQLite3(); err != nil {
+		return -1
+	}
+	return sqlite3_threadsafe()
+}
+
+var singleThreadWarning sync.Once
+
+// mutexFlags adjusts the open flags for the library's threading mode. A
+// multi-thread build leaves connections without their own mutex unless asked,
+// so connections default to FULLMUTEX to keep raw handle access safe; an
+// explicit _mutex setting is kept. A single-thread build is only warned about,
+// since no flag can make it safe.
+func mutexFlags(flags, threadsafe int) int {
+	switch threadsafe {
+	case 0:
+		singleThreadWarning.Do(func() {
+			log.Printf("sqlite: library is compiled single-threaded (SQLITE_THREADSAFE=0); do not use connections concurrently")
+		})
+	case 2:
+		if flags&(SQLITE_OPEN_NOMUTEX|SQLITE_OPEN_FULLMUTEX) == 0 {
+			flags |= SQLITE_OPEN_FULLMUTEX
+		}
+	}
+	return flags
+}